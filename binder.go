@@ -0,0 +1,109 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// TypeBinder bind and read the flag of the custom type, e.g. UUID, color, semver
+// Bind registers the flag, defaultVal is always a value of the registered type T:
+// the parsed `default` label if set, otherwise the current value of the field
+// Read returns the value of the flag from viper, the value is set to the field by `ReadFlags`
+//
+// The `default` label is parsed by the binder itself: Bind with the zero value of T,
+// then `Set` the label on the registered flag and Read it back
+type TypeBinder interface {
+	Bind(fs *flag.FlagSet, name, short, desc string, defaultVal builtin.Any) error
+	Read(v *viper.Viper, name string) (builtin.Any, error)
+}
+
+// reflect.Type -> TypeBinder
+var typeBinders sync.Map
+
+// RegisterType register the binder of type T, the registered types are checked before the builtin types
+func RegisterType[T any](binder TypeBinder) {
+	typeBinders.Store(reflect.TypeOf((*T)(nil)).Elem(), binder)
+}
+
+func lookupTypeBinder(t reflect.Type) (TypeBinder, bool) {
+	binder, ok := typeBinders.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return binder.(TypeBinder), true
+}
+
+// isValueType the type is bound as a single flag, even if it is a struct or a pointer
+func isValueType(t reflect.Type) bool {
+	if _, ok := lookupTypeBinder(t); ok {
+		return true
+	}
+	return isFlagValueType(t) || isTextType(t)
+}
+
+func bindCustom(flagSet *flag.FlagSet, binder TypeBinder, fValue reflect.Value, field reflect.StructField, tag *tagData) error {
+	defaultVal := fValue.Interface()
+	if len(tag.Default) > 0 {
+		value, err := parseDefault(fValue.Type(), tag)
+		if err != nil {
+			return err
+		}
+		defaultVal = value.Interface()
+	}
+
+	if err := binder.Bind(flagSet, tag.Name, tag.Short, tag.Desc, defaultVal); err != nil {
+		return fmt.Errorf("field `%s`: %w", field.Name, err)
+	}
+	return nil
+}
+
+func readCustom(vp *viper.Viper, binder TypeBinder, fValue reflect.Value, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	value, err := binder.Read(vp, tag.key())
+	if err != nil {
+		return fmt.Errorf("field `%s`: %w", field.Name, err)
+	}
+	return setValue(fValue, field, tag, value, cfg)
+}
+
+// parseCustom parse the string with the binder through a scratch flag set and viper,
+// the flag is bound with the zero value and set with the string
+func parseCustom(binder TypeBinder, v reflect.Value, s string, tag *tagData) error {
+	flagSet := flag.NewFlagSet("", flag.ContinueOnError)
+	if err := binder.Bind(flagSet, tag.Name, "", "", reflect.Zero(v.Type()).Interface()); err != nil {
+		return err
+	}
+	if err := flagSet.Set(tag.Name, s); err != nil {
+		return err
+	}
+	vp := viper.New()
+	if err := vp.BindPFlags(flagSet); err != nil {
+		return err
+	}
+
+	value, err := binder.Read(vp, tag.Name)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case !rv.IsValid():
+	case rv.Type().AssignableTo(v.Type()):
+		v.Set(rv)
+	case rv.Type().ConvertibleTo(v.Type()):
+		v.Set(rv.Convert(v.Type()))
+	default:
+		return fmt.Errorf("cannot be set with %T", value)
+	}
+	return nil
+}
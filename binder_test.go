@@ -0,0 +1,85 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type testVersion struct {
+	Major, Minor int
+}
+
+func (v testVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// testVersionBinder records the default values passed to Bind
+type testVersionBinder struct {
+	defaults map[string]builtin.Any
+}
+
+func (b *testVersionBinder) Bind(fs *flag.FlagSet, name, short, desc string, defaultVal builtin.Any) error {
+	v, ok := defaultVal.(testVersion)
+	if !ok {
+		return fmt.Errorf("default of %s is %T, want testVersion", name, defaultVal)
+	}
+	b.defaults[name] = v
+	fs.StringP(name, short, v.String(), desc)
+	return nil
+}
+
+func (b *testVersionBinder) Read(vp *viper.Viper, name string) (builtin.Any, error) {
+	var v testVersion
+	_, err := fmt.Sscanf(vp.GetString(name), "%d.%d", &v.Major, &v.Minor)
+	return v, err
+}
+
+func TestTypeBinderDefault(t *testing.T) {
+	binder := &testVersionBinder{defaults: make(map[string]builtin.Any)}
+	RegisterType[testVersion](binder)
+	type Config struct {
+		API     testVersion `flag:"api,default:1.2"`
+		Storage testVersion `flag:"storage"`
+	}
+
+	c := Config{Storage: testVersion{Major: 3}}
+	cmd, vp := newTestCommand()
+	if err := BindFlags(cmd, &c, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	if got := binder.defaults["api"]; got != (testVersion{Major: 1, Minor: 2}) {
+		t.Errorf("default of api = %v, want the parsed label 1.2", got)
+	}
+	if got := binder.defaults["storage"]; got != (testVersion{Major: 3}) {
+		t.Errorf("default of storage = %v, want the field value 3.0", got)
+	}
+
+	cmd.SetArgs([]string{"--storage", "4.5"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var r Config
+	if err := ReadFlags(&r, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{API: testVersion{1, 2}, Storage: testVersion{4, 5}}); r != want {
+		t.Errorf("ReadFlags = %+v, want %+v", r, want)
+	}
+
+	if err := ResetToDefaults(&r); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{API: testVersion{1, 2}}); r != want {
+		t.Errorf("ResetToDefaults = %+v, want %+v", r, want)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	"github.com/mars315/autoflags/lib/stringx"
+)
+
+// ValidateDefaults parse the `default` labels as the field types without binding,
+// and check the `oneof`, `min`, `max` and `pattern` labels
+// all errors are joined, e.g. used in `TestMain` or `init()`
+func ValidateDefaults(v0 builtin.Any, opts ...FlagOption) error {
+	infos, err := ListFlags(v0, opts...)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range infos {
+		info := &infos[i]
+		if len(info.Default) > 0 {
+			if _, err := parseDefault(info.typ, info.tag); err != nil {
+				errs = append(errs, &fieldError{path: info.FieldPath, err: err})
+				continue
+			}
+		}
+
+		var validators []validatorFunc
+		if len(info.tag.OneOf) > 0 {
+			validators = append(validators, oneOfValidator)
+		}
+		if len(info.tag.Min) > 0 || len(info.tag.Max) > 0 {
+			validators = append(validators, rangeValidator)
+		}
+		if len(info.tag.Pattern) > 0 {
+			validators = append(validators, patternValidator)
+		}
+		for _, fn := range validators {
+			if _, _, err := fn(info.field, info.tag); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ResetToDefaults set the fields to the `default` labels, the fields without default are set to the zero value
+// the nil struct pointers are allocated, all parse errors are joined
+func ResetToDefaults(v0 builtin.Any, opts ...FlagOption) error {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return err
+	}
+
+	if err := checkStructPointer("ResetToDefaults", v0); err != nil {
+		return err
+	}
+
+	var errs []error
+	err = walkFlags(reflect.ValueOf(v0).Elem(), "", cfg, true, func(fValue reflect.Value, info *FlagInfo) error {
+		// the pointer to primitive without default is reset to nil
+		if isPrimitivePointer(fValue.Type()) && len(info.tag.Default) == 0 {
+			fValue.Set(reflect.Zero(fValue.Type()))
+			return nil
+		}
+		value, err := parseDefault(info.typ, info.tag)
+		if err != nil {
+			errs = append(errs, &fieldError{path: info.FieldPath, err: err})
+			return nil
+		}
+		fieldElem(fValue).Set(value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// parseDefault parse the `default` label as the value of type t,
+// the error has no field path, it is added by the caller, e.g. `wrapFieldError` of `bindFlags`
+func parseDefault(t reflect.Type, tag *tagData) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if len(tag.Default) == 0 {
+		return v, nil
+	}
+	if err := parseValue(v, tag.Default, tag); err != nil {
+		return v, fmt.Errorf("cannot parse default value %q as %s: %w", tag.Default, t, err)
+	}
+	return v, nil
+}
+
+// parseValue v must be addressable, e.g. net.IP is parsed by `encoding.TextUnmarshaler`
+func parseValue(v reflect.Value, s string, tag *tagData) error {
+	if binder, ok := lookupTypeBinder(v.Type()); ok {
+		return parseCustom(binder, v, s, tag)
+	}
+	if v.Type() == timeType {
+		t, err := time.Parse(timeLayout(tag), strings.TrimSpace(s))
+		v.Set(reflect.ValueOf(t))
+		return err
+	}
+	if isHexInt(v.Type(), tag) {
+		return parseHexInt(v, s)
+	}
+	if value, ok := flagValue(v); ok {
+		return value.Set(s)
+	}
+	if text, ok := textUnmarshaler(v); ok {
+		return text.UnmarshalText([]byte(s))
+	}
+
+	if isDurationType(v.Type()) {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		v.SetInt(int64(d))
+		return err
+	}
+
+	switch v.Type() {
+	case reflect.TypeOf([]byte{}):
+		var (
+			b   []byte
+			err error
+		)
+		switch tag.Format {
+		case FormatHex:
+			b, err = hex.DecodeString(strings.TrimSpace(s))
+		default:
+			b, err = base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		}
+		v.SetBytes(b)
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := stringx.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		return setInt(v, stringx.AtoiE[int], s)
+	case reflect.Int32:
+		return setInt(v, stringx.AtoiE[int32], s)
+	case reflect.Int64:
+		return setInt(v, stringx.AtoiE[int64], s)
+	case reflect.Float32:
+		f, err := stringx.AtofE[float32](strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetFloat(float64(f))
+	case reflect.Float64:
+		f, err := stringx.AtofE[float64](strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		tokens := stringx.SafeTokens(s, tag.Sep)
+		l := reflect.MakeSlice(v.Type(), len(tokens), len(tokens))
+		for i, token := range tokens {
+			if err := parseValue(l.Index(i), token, tag); err != nil {
+				return err
+			}
+		}
+		v.Set(l)
+	case reflect.Map:
+		var m builtin.Any
+		var err error
+		switch v.Type() {
+		case reflect.TypeOf(map[string]bool{}):
+			m, err = parseBoolMap(s, tag.Sep)
+		case jsonMapType:
+			m, err = parseJSONMap(s)
+		default:
+			return fmt.Errorf("unsupported map type: %s", v.Type())
+		}
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported type: %s", v.Kind())
+	}
+	return nil
+}
+
+func setInt[T builtin.SignedInteger](v reflect.Value, atoi func(string) (T, error), s string) error {
+	n, err := atoi(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	v.SetInt(int64(n))
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/mars315/autoflags/lib/builtin"
+)
+
+// ReadFlagsFromEnv read the fields from the env vars without viper, cobra and pflag
+// the env name is the upper snake case of the flag name with the prefix, e.g. MYAPP_SERVER_HTTP_PORT
+// the fields of the unset env vars are unchanged
+func ReadFlagsFromEnv(v0 builtin.Any, envPrefix string, opts ...FlagOption) error {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return err
+	}
+
+	if err := checkStructPointer("ReadFlagsFromEnv", v0); err != nil {
+		return err
+	}
+
+	err = walkFlags(reflect.ValueOf(v0).Elem(), "", cfg, true, func(fValue reflect.Value, info *FlagInfo) error {
+		name := envName(envPrefix, info.Name)
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+
+		value := reflect.New(info.typ).Elem()
+		if err := parseValue(value, s, info.tag); err != nil {
+			return fmt.Errorf("field %s: cannot parse env %s value %q as %s: %w", info.FieldPath, name, s, info.typ, err)
+		}
+		return setValue(fieldElem(fValue), info.field, info.tag, value.Interface(), cfg)
+	})
+	if err != nil {
+		return err
+	}
+	return validate(v0, cfg)
+}
+
+// envName e.g. ("app", "server.http-port") -> APP_SERVER_HTTP_PORT
+func envName(prefix, name string) string {
+	name = strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	if len(prefix) > 0 {
+		name = prefix + "_" + name
+	}
+	return strings.ToUpper(name)
+}
@@ -49,7 +49,6 @@ import (
 
 func main() {
 	v := new(Flag)
-	v.A3 = new(A3)
 	rootCmd := &cobra.Command{
 		Use: "test auto read flag",
 		Run: func(cmd *cobra.Command, args []string) {
@@ -77,7 +76,7 @@ type Flag struct {
 	Short    string        `mapstructure:"short, desc:short, default:s"`
 	Age      int           `mapstructure:"age, desc:age, default:18"`
 	Usage    string        `mapstructure:"usage, desc:usage, default:usage"`
-	KeepTime time.Duration `mapstructure:"keep,omitempty, default:1s""`
+	KeepTime time.Duration `mapstructure:"keep,omitempty, default:1s"`
 	NoUse    string        `mapstructure:"-"`
 }
 
@@ -0,0 +1,152 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	"github.com/mars315/autoflags/lib/stringx"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// the formats of `ExportFlags`
+const (
+	ExportJSON = "json"
+	ExportYAML = "yaml"
+	ExportTOML = "toml"
+	ExportEnv  = "env"
+)
+
+// ExportFlags export the current flag values as the config file, e.g. `--save-config`
+// format is one of json, yaml, toml and env, the nested flags are rendered in the hierarchical structure
+// the `default` labels are used if cmd is nil or the flag is not registered
+func ExportFlags(cmd *cobra.Command, v0 builtin.Any, format string, opts ...FlagOption) ([]byte, error) {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ListFlags(v0, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == ExportEnv {
+		var sb strings.Builder
+		for i := range infos {
+			sb.WriteString(envVarName(&infos[i], cfg) + "=" + envValue(exportString(cmd, &infos[i])) + "\n")
+		}
+		return []byte(sb.String()), nil
+	}
+
+	root := make(map[string]builtin.Any)
+	for i := range infos {
+		keys := splitKey(infos[i].tag.key(), cfg)
+		node := root
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := node[key].(map[string]builtin.Any)
+			if !ok {
+				child = make(map[string]builtin.Any)
+				node[key] = child
+			}
+			node = child
+		}
+		node[keys[len(keys)-1]] = exportValue(cmd, &infos[i])
+	}
+	tree := exportTree(root)
+
+	switch format {
+	case ExportJSON:
+		return json.MarshalIndent(tree, "", "  ")
+	case ExportYAML:
+		return yaml.Marshal(tree)
+	case ExportTOML:
+		return toml.Marshal(tree)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// exportFlag the registered flag of the command
+func exportFlag(cmd *cobra.Command, info *FlagInfo) *flag.Flag {
+	if cmd == nil {
+		return nil
+	}
+	if f := cmd.Flags().Lookup(info.Name); f != nil {
+		return f
+	}
+	return cmd.PersistentFlags().Lookup(info.Name)
+}
+
+// exportValue the typed value, e.g. []builtin.Any of the slice flag
+func exportValue(cmd *cobra.Command, info *FlagInfo) builtin.Any {
+	f := exportFlag(cmd, info)
+	if f == nil {
+		return typedValue(info.typ, info.Default, info.tag.Sep)
+	}
+
+	if slice, ok := f.Value.(flag.SliceValue); ok && info.Kind == reflect.Slice && !isStringType(info.typ) {
+		list := make([]builtin.Any, 0)
+		for _, item := range slice.GetSlice() {
+			list = append(list, typedValue(info.typ.Elem(), item, info.tag.Sep))
+		}
+		return list
+	}
+	// the separator of the map value is always comma
+	return typedValue(info.typ, f.Value.String(), TagLabelSep)
+}
+
+// exportString the slices are joined with comma, same as `GenerateEnvTemplate`
+func exportString(cmd *cobra.Command, info *FlagInfo) string {
+	f := exportFlag(cmd, info)
+	switch {
+	case f == nil && info.Kind == reflect.Slice && !isStringType(info.typ):
+		return strings.Join(stringx.SafeTokens(info.Default, info.tag.Sep), ",")
+	case f == nil:
+		return info.Default
+	}
+
+	if slice, ok := f.Value.(flag.SliceValue); ok {
+		return strings.Join(slice.GetSlice(), ",")
+	}
+	return f.Value.String()
+}
+
+// exportTree the maps of the []struct elements, e.g. {"0": ..., "1": ...}, are converted to the lists
+func exportTree(node map[string]builtin.Any) builtin.Any {
+	index := true
+	for key, value := range node {
+		if child, ok := value.(map[string]builtin.Any); ok {
+			node[key] = exportTree(child)
+		}
+		index = index && isIndexKey(key)
+	}
+	if !index || len(node) == 0 {
+		return node
+	}
+
+	keys := make([]string, 0, len(node))
+	for key := range node {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return stringx.Atoi[int](keys[i]) < stringx.Atoi[int](keys[j])
+	})
+
+	list := make([]builtin.Any, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, node[key])
+	}
+	return list
+}
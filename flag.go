@@ -10,8 +10,10 @@
 //	int, int32, int64,
 //	time.Duration
 //	float32, float64,
-//	[]string, []int
-//	struct, struct pointer
+//	[]string, []int, []time.Duration, []net.IP
+//	[]byte (base64, `format:hex` for hex)
+//	struct, struct pointer, []struct (`--name.0.field`)
+//	encoding.TextUnmarshaler (as string flag)
 //
 // first label is the flag name
 //
@@ -21,6 +23,26 @@
 // - desc: description
 // - default: default value
 // - squash: squash all anonymous structs
+// - format: value encoding, e.g. `format:hex` for []byte, or the hexadecimal input of the integers, e.g. 0xFF00
+// - required: the flag must be set, cannot be used with `default`
+// - hidden: hide the flag from the help output
+// - deprecated: deprecation message, e.g. `deprecated:use --port instead`
+// - env: read the value from the environment variable, e.g. `env:DB_PASS`
+// - persistent: register the flag in `cmd.PersistentFlags()`
+// - count: int field counts the occurrences of the flag, e.g. `-vvv`
+// - array: []string field doesn't split the value on commas
+// - sep: separator of the slice default value, e.g. `default:a|b|c,sep:|`,
+//   the `,` separator is escaped as `\\,` in the struct tag, e.g. `default:1s\\,5s`, otherwise the default is truncated to `[1s]`
+// - viperkey: the key used to read the value from viper, e.g. `viperkey:database.host`
+// - oneof: the allowed values separated by `|`, e.g. `oneof:json|yaml|toml`
+// - min, max: the range of numeric fields, e.g. `min:1,max:65535`
+// - pattern: RE2 regular expression of string fields, e.g. `pattern:^[^@]+@[^@]+$`
+// - required-with: flags that must be set together, separated by `:`, e.g. `required-with:tls-key`
+// - exclusive-with: flags that cannot be set together, separated by `:`, e.g. `exclusive-with:yaml:toml`
+// - filename: complete the flag with files, extensions are optional, e.g. `filename:.yaml:.json`
+// - dirname: complete the flag with directories, cannot be used with `filename`
+// - annotation: the flag annotations separated by `:`, the values by `|`, e.g. `annotation:group=net:scope=a|b`
+// - layout: the layout of time.Time fields, default is RFC3339, e.g. `layout:2006-01-02`
 // - `-` skip this field
 //
 // e.g.
@@ -65,27 +87,71 @@
 package autoflags
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mars315/autoflags/lib/builtin"
 	"github.com/mars315/autoflags/lib/stringx"
 	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 const (
-	TagName         = "flag"
-	TagLabelShort   = "short"
-	TagLabelDesc    = "desc"
-	TagLabelDefault = "default"
-	TagLabelSquash  = "squash"
-	TagLabelSkip    = "-"
-	TagLabelSep     = ","
+	TagName               = "flag"
+	TagLabelShort         = "short"
+	TagLabelDesc          = "desc"
+	TagLabelDefault       = "default"
+	TagLabelSquash        = "squash"
+	TagLabelFormat        = "format"
+	TagLabelRequired      = "required"
+	TagLabelHidden        = "hidden"
+	TagLabelDeprecated    = "deprecated"
+	TagLabelEnv           = "env"
+	TagLabelPersistent    = "persistent"
+	TagLabelCount         = "count"
+	TagLabelArray         = "array"
+	TagLabelSliceSep      = "sep"
+	TagLabelViperKey      = "viperkey"
+	TagLabelOneOf         = "oneof"
+	TagLabelMin           = "min"
+	TagLabelMax           = "max"
+	TagLabelPattern       = "pattern"
+	TagLabelRequiredWith  = "required-with"
+	TagLabelExclusiveWith = "exclusive-with"
+	TagLabelFilename      = "filename"
+	TagLabelDirname       = "dirname"
+	TagLabelLayout        = "layout"
+	TagLabelAnnotation    = "annotation"
+	TagLabelSkip          = "-"
+	TagLabelSep           = ","
+)
+
+const (
+	// FormatHex `format:hex` hex encoded value of []byte, or the hexadecimal input of the integers, e.g. 0xFF00
+	FormatHex = "hex"
+)
+
+const (
+	// LogLevelDebug the binding decisions, see `WithLoggerOption`
+	LogLevelDebug = "debug"
+	// LogLevelWarn the missing file of `WithFromFileOption`
+	LogLevelWarn = "warn"
+	// LogLevelError the errors ignored by the auto unmarshal of `cmd.PreRun`
+	LogLevelError = "error"
 )
 
 type (
@@ -104,18 +170,119 @@ type (
 		squash bool
 		// parent (squash == false)
 		parent []string
+		// the go field names from the top struct, used by the error messages, e.g. Server.Database.Port
+		fieldPath []string
 		// read the flag value from viper
 		autoUnMarshalFlag bool
 		// executed before `UnmarshalFlags`, can be used to add the data source of `viper`
 		preAutoUnMarshal func(cmd *cobra.Command, args []string)
 		//  executed before `UnmarshalFlags`, can be used to add the data source of `viper`
 		preAutoUnMarshalE func(cmd *cobra.Command, args []string) error
+		// executed before `UnmarshalFlags` with `cmd.Context()`
+		preAutoUnMarshalContext func(ctx context.Context, cmd *cobra.Command, args []string) error
+		// executed after `UnmarshalFlags`, can be used to validate or initialize with the populated config
+		postAutoUnMarshal func(cmd *cobra.Command, args []string)
+		// executed after `UnmarshalFlags`, can be used to validate or initialize with the populated config
+		postAutoUnMarshalE func(cmd *cobra.Command, args []string) error
+		// cmd.PersistentPreRun(E), executed after `UnmarshalFlags`
+		persistentPreRun  func(cmd *cobra.Command, args []string)
+		persistentPreRunE func(cmd *cobra.Command, args []string) error
 		// The tag name that flag reads for field names, default is "flag"
 		tagName string
 		// The tag label separator, default is  ","
 		tagLabelSep string
 		// persist flags `cmd.PersistentFlags()`  default cmd.Flags()
 		persist bool
+		// re-read the bound structs on the config file changes, see `WithWatchConfigOption`
+		watchConfig    bool
+		onConfigChange func(err error)
+		// at most rateLimit re-reads per rateWindow, see `WithFlagRateLimitOption`
+		rateLimit  int
+		rateWindow time.Duration
+		// applied in sequence to every value read by `ReadFlags` before it is set to the field
+		transformers []FlagTransformer
+		// return an error for nil struct pointer fields instead of allocating them
+		strictNilPointers bool
+		// prepended to all flag names, before the nested struct prefix
+		prefix string
+		// the separator between the prefix and the flag name, default is "."
+		prefixSep string
+		// the separator between the nested struct and the field names, default is "."
+		nestedSep string
+		// applied in sequence to the full flag name before registration
+		nameTransformers []func(string) string
+		// viper.SetEnvPrefix
+		envPrefix string
+		// viper.AutomaticEnv
+		autoEnv bool
+		// loaded before the auto unmarshal
+		configFile string
+		configType string
+		// loaded before the flags are registered, see `WithFromFileOption`
+		fromFile string
+		// called for every field error instead of returning the first one
+		errorHandler func(error)
+		// the errors passed to errorHandler
+		errs []error
+		// called with the struct pointer after `ReadFlags` and `UnmarshalFlags`
+		validate func(builtin.Any) error
+		// the custom hooks of `UnmarshalFlags`
+		decodeHooks []mapstructure.DecodeHookFunc
+		// `UnmarshalFlags` returns an error on the unknown viper keys
+		strict bool
+		// `UnmarshalFlags` converts the mismatched types, e.g. int to string, default is true same as viper
+		weaklyTypedInput bool
+		// the generated docs include the hidden flags
+		includeHidden bool
+		// parse the tags without the cache
+		noCache bool
+		// the struct types being traversed, to detect the self-referencing structs
+		visitedTypes map[reflect.Type]bool
+		// the fields of these types and kinds are skipped silently
+		ignoreTypes []reflect.Type
+		ignoreKinds []reflect.Kind
+		// the annotations of all bound flags, see `WithAnnotationOption`
+		annotations map[string][]string
+		// the fields are bound only if it returns true, see `WithFieldFilterOption`
+		fieldFilter func(reflect.StructField) bool
+		// the flag name of the fields without explicit name, default is `strings.ToLower`
+		fieldNameFunc func(string) string
+		// WithCamelToKebabOption and WithCamelToSnakeOption are mutually exclusive
+		camelToKebab bool
+		camelToSnake bool
+		// dynamic default of the flag, see `WithDefaultProviderOption`
+		defaultProvider func(string) (string, bool)
+		// the viper instance, default is the global `viper.GetViper()`
+		viper *viper.Viper
+		// cmd.MarkFlagsRequiredTogether, collected from the `required-with` label and `WithRequiredTogetherOption`
+		requiredTogether flagGroups
+		// cmd.MarkFlagsMutuallyExclusive, collected from the `exclusive-with` label and `WithMarkFlagsMutuallyExclusiveOption`
+		mutuallyExclusive flagGroups
+		// the flag set to register, default is `cmd.Flags()`, see `WithFlagSetOption`
+		flagSet *flag.FlagSet
+		// the flag name normalization, see `WithNormalizeOption`
+		normalize func(f *flag.FlagSet, name string) flag.NormalizedName
+		// flag name -> the dynamic shell completion, see `WithCompletionFuncOption`
+		completionFuncs map[string]func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+		// cmd.Args, see `WithArgsOption`
+		args cobra.PositionalArgs
+		// cmd.SilenceUsage and cmd.SilenceErrors, nil if the option is not set
+		silenceUsage  *bool
+		silenceErrors *bool
+		// the flags are listed in the declaration order in help, see `WithDisableSortOption`
+		disableSort bool
+		// the diagnostic logger, see `WithLoggerOption`
+		logger func(level, msg string, fields ...builtin.Any)
+		// write the `default` labels to viper at bind time, see `WithSetViperDefaultsOption`
+		setViperDefaults bool
+		// the persistent flags of the parents are also bound to viper, see `WithInheritPersistentFlagsOption`
+		inheritParents []*cobra.Command
+		// skip `viper.BindPFlags` only, see `WithDisableViperBindPFlagsOption`
+		disableViperBind bool
+		// skip `viper.BindPFlags`, see `WithNoViperOption`
+		noViper bool
+		// cmd.MarkFlagsOneRequired, see `WithAtLeastOneOption`
+		oneRequired flagGroups
 	}
 )
 
@@ -128,33 +295,170 @@ func BindAndExecute(cmd *cobra.Command, v0 builtin.Any, opts ...FlagOption) erro
 	return cmd.Execute()
 }
 
+// BindAndExecuteContext automatically bind flag and execute with the context, see `cmd.ExecuteContext`
+func BindAndExecuteContext(ctx context.Context, cmd *cobra.Command, v0 builtin.Any, opts ...FlagOption) error {
+	if err := BindFlags(cmd, v0, opts...); err != nil {
+		return err
+	}
+
+	return cmd.ExecuteContext(ctx)
+}
+
+// NewCommandFromStruct create the command with `Use`, `Short` and `Run`, and bind the flags of v0
+func NewCommandFromStruct(name, short string, v0 builtin.Any, run func(*cobra.Command, []string), opts ...FlagOption) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   name,
+		Short: short,
+		Run:   run,
+	}
+	if err := BindFlags(cmd, v0, opts...); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
 // BindFlags v0 must be a pointer and the structure where the variable is located
-// supported type: string, bool, int, int32, int64, float32, float64, []string, []int time.Duration
+// supported type: string, bool, int, int32, int64, float32, float64, []string, []int, []time.Duration, []net.IP, []byte, time.Duration
 //
-//	struct and struct pointer
+//	struct, struct pointer and []struct
 func BindFlags(cmd *cobra.Command, v0 builtin.Any, opts ...FlagOption) error {
-	autoMarshalOption(cmd, v0, opts...)
-	if err := bindFlags(cmd, v0, defaultFlagConfig(opts...)); err != nil {
+	return BindMultipleFlags(cmd, []builtin.Any{v0}, opts...)
+}
+
+// BindMultipleFlags bind the flags of multiple structs to the same command with the same options
+// the duplicate flag names between the structs are returned as an error before any registration
+func BindMultipleFlags(cmd *cobra.Command, structs []builtin.Any, opts ...FlagOption) error {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return err
+	}
+	if len(structs) > 1 {
+		if err := checkConflicts(structs, opts...); err != nil {
+			return err
+		}
+	}
+
+	if err := readFromFile(cfg); err != nil {
+		return err
+	}
+
+	for _, v0 := range structs {
+		autoMarshalOption(cmd, v0, opts...)
+	}
+	if err := bindCommand(cmd, cfg, structs...); err != nil {
+		return err
+	}
+	setNormalizeFunc(cfg, getFlagSet(cmd, cfg), cmd.Flags(), cmd.PersistentFlags())
+	if err := configureCommand(cmd, cfg); err != nil {
+		return err
+	}
+	if cfg.noViper {
+		return nil
+	}
+
+	flagSets := []*flag.FlagSet{getFlagSet(cmd, cfg), cmd.PersistentFlags()}
+	for _, parent := range cfg.inheritParents {
+		flagSets = append(flagSets, parent.PersistentFlags())
+	}
+	if err := bindPFlags(cfg, flagSets...); err != nil {
+		return err
+	}
+
+	if err := setViperDefaults(cfg, structs...); err != nil {
+		return err
+	}
+
+	// after `BindPFlags`, the priority is flag > env > config
+	if cfg.autoEnv {
+		getViper(cfg).AutomaticEnv()
+	}
+	watchConfig(cfg, structs...)
+	return nil
+}
+
+// BindPersistentFlags bind all flags to `cmd.PersistentFlags()`
+// same as `BindFlags(cmd, v0, append(opts, WithPersistentFlagsOption())...)`
+func BindPersistentFlags(cmd *cobra.Command, v0 builtin.Any, opts ...FlagOption) error {
+	return BindFlags(cmd, v0, append(opts, WithPersistentFlagsOption())...)
+}
+
+// BindFlagSet bind flags to the pflag.FlagSet without cobra
+// the cobra-specific features, e.g. auto unmarshal, required and the flag groups, are no-ops
+func BindFlagSet(fs *flag.FlagSet, v0 builtin.Any, opts ...FlagOption) error {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
 		return err
 	}
 
-	return viper.BindPFlags(getFlagSet(cmd, defaultFlagConfig(opts...)))
+	// the command is only used for the struct traversal
+	cmd := &cobra.Command{}
+	cfg.flagSet = nil
+	if err := bindCommand(cmd, cfg, v0); err != nil {
+		return err
+	}
+
+	// the persistent flags may be merged into `cmd.Flags()`
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	flags.AddFlagSet(cmd.Flags())
+	flags.AddFlagSet(cmd.PersistentFlags())
+	var err0 error
+	flags.VisitAll(func(f *flag.Flag) {
+		switch {
+		case err0 != nil:
+		case fs.Lookup(f.Name) != nil:
+			err0 = fmt.Errorf("flag redefined: %s", f.Name)
+		case len(f.Shorthand) > 0 && fs.ShorthandLookup(f.Shorthand) != nil:
+			err0 = fmt.Errorf("unable to redefine %q shorthand: %s", f.Shorthand, f.Name)
+		}
+	})
+	if err0 != nil {
+		return err0
+	}
+	fs.AddFlagSet(flags)
+	setNormalizeFunc(cfg, fs)
+	if cfg.noViper {
+		return nil
+	}
+
+	if err := bindPFlags(cfg, fs); err != nil {
+		return err
+	}
+	if err := setViperDefaults(cfg, v0); err != nil {
+		return err
+	}
+	if cfg.autoEnv {
+		getViper(cfg).AutomaticEnv()
+	}
+	watchConfig(cfg, v0)
+	return nil
 }
 
 // ReadFlags read flag value from viper
-// supported type: string, bool, int, int32, int64, float32, float64, []string, []int time.Duration
+// supported type: string, bool, int, int32, int64, float32, float64, []string, []int, []time.Duration, []net.IP, []byte, time.Duration
 //
-//	struct and struct pointer
+//	struct, struct pointer and []struct
 func ReadFlags(v0 builtin.Any, opts ...FlagOption) error {
-	cfg := defaultFlagConfig(opts...)
-	return readFlags(v0, cfg)
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return err
+	}
+	if cfg.noViper {
+		return errNoViper
+	}
+	if err := readFlags(v0, cfg); err != nil {
+		return err
+	}
+	return validate(v0, cfg)
 }
 
 // UnmarshalFlags unmarshal flag value from viper
 // use `mapstructure` to unmarshal
 func UnmarshalFlags(v0 builtin.Any, opts ...FlagOption) error {
-	defaultOpts := castConfigOptions(defaultFlagConfig(opts...))
-	return viper.Unmarshal(v0, defaultOpts...)
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return err
+	}
+	return unmarshalFlags(v0, cfg)
 }
 
 /////////////////////////////////////////////////////// option ///////////////////////////////////////////////////////
@@ -166,6 +470,83 @@ func WithPersistFlagSetOption() FlagOption {
 	}
 }
 
+// WithPersistentFlagsOption register all flags in `cmd.PersistentFlags()` without the per-field `persistent` label,
+// same as `WithPersistFlagSetOption`
+func WithPersistentFlagsOption() FlagOption {
+	return WithPersistFlagSetOption()
+}
+
+// WithFlagSetOption register all flags in fs instead of `cmd.Flags()`, e.g. `cmd.InheritedFlags()` or a custom flag set,
+// `WithFlagSetOption(cmd.PersistentFlags())` is same as `WithPersistentFlagsOption`, ignored by `BindFlagSet`
+func WithFlagSetOption(fs *flag.FlagSet) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.flagSet = fs
+	}
+}
+
+// WithNormalizeOption set the normalize func of `cmd.Flags()` and `cmd.PersistentFlags()` after binding,
+// e.g. make `--log_level` and `--log-level` interchangeable
+// The flag names of the tags are normalized before registration, so the viper keys are the normalized names, the flag set passed to fn is nil there
+func WithNormalizeOption(fn func(f *flag.FlagSet, name string) flag.NormalizedName) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.normalize = fn
+	}
+}
+
+// WithCompletionFuncOption register the dynamic shell completion of the flag, multiple calls with different flag names accumulate
+func WithCompletionFuncOption(flagName string, fn func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)) FlagOption {
+	return func(cfg *FlagConfig) {
+		if cfg.completionFuncs == nil {
+			cfg.completionFuncs = make(map[string]func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective))
+		}
+		cfg.completionFuncs[flagName] = fn
+	}
+}
+
+// WithArgsOption set `cmd.Args`, e.g. WithArgsOption(cobra.ExactArgs(1))
+func WithArgsOption(args cobra.PositionalArgs) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.args = args
+	}
+}
+
+// WithSilenceUsageOption set `cmd.SilenceUsage`
+func WithSilenceUsageOption(silence bool) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.silenceUsage = &silence
+	}
+}
+
+// WithSilenceErrorsOption set `cmd.SilenceErrors`
+func WithSilenceErrorsOption(silence bool) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.silenceErrors = &silence
+	}
+}
+
+// WithDisableSortOption list the flags in the struct field declaration order in help instead of alphabetically
+func WithDisableSortOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.disableSort = true
+	}
+}
+
+// WithLoggerOption log the binding decisions of each field and the errors ignored by the auto unmarshal,
+// the level is `LogLevelDebug` or `LogLevelError`, the fields are key-value pairs, e.g. "flag", "port"
+func WithLoggerOption(logger func(level, msg string, fields ...builtin.Any)) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.logger = logger
+	}
+}
+
+// WithSetViperDefaultsOption write the `default` labels to the default layer of viper at bind time,
+// so that `viper.GetX` returns the defaults before `cmd.Execute()`, e.g. in `init()`
+func WithSetViperDefaultsOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.setViperDefaults = true
+	}
+}
+
 // WithTagNameOption custom tag name
 func WithTagNameOption(tag string) FlagOption {
 	return func(cfg *FlagConfig) {
@@ -202,6 +583,25 @@ func WithSquashOption(squash bool) FlagOption {
 	}
 }
 
+// WithWatchConfigOption watch the config file of viper after binding, the bound structs are re-read by `UnmarshalFlags`
+// on every change, then fn is called with the error of the re-read, fn can be nil
+// The re-read runs in the watcher goroutine of viper, see `viper.WatchConfig`
+func WithWatchConfigOption(fn func(err error)) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.watchConfig = true
+		cfg.onConfigChange = fn
+	}
+}
+
+// WithFlagRateLimitOption at most n re-reads of `WithWatchConfigOption` per window, the changes over the limit are dropped,
+// e.g. the editors writing the file several times on save. n <= 0 or window <= 0 means no limit
+func WithFlagRateLimitOption(n int, window time.Duration) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.rateLimit = n
+		cfg.rateWindow = window
+	}
+}
+
 // WithPreAutoUnMarshalOption executed before `UnmarshalFlags`, can be used to add the data source of `viper`
 func WithPreAutoUnMarshalOption(pre func(cmd *cobra.Command, args []string)) FlagOption {
 	return func(cfg *FlagConfig) {
@@ -216,207 +616,1309 @@ func WithPreAutoUnMarshalEOption(preE func(cmd *cobra.Command, args []string) er
 	}
 }
 
-/////////////////////////////////////////////////////// implement ///////////////////////////////////////////////////////
+// WithPreAutoUnMarshalContextOption executed before `UnmarshalFlags` with `cmd.Context()`, see `BindAndExecuteContext`
+func WithPreAutoUnMarshalContextOption(pre func(ctx context.Context, cmd *cobra.Command, args []string) error) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.preAutoUnMarshalContext = pre
+	}
+}
 
-func bindFlags(cmd *cobra.Command, v0 builtin.Any, cfg *FlagConfig) error {
-	if reflect.TypeOf(v0).Kind() != reflect.Pointer {
-		return fmt.Errorf("v0 must be pointer")
+// WithPostAutoUnMarshalOption executed after `UnmarshalFlags`, in both `cmd.PreRun` and `cmd.PreRunE`
+func WithPostAutoUnMarshalOption(post func(cmd *cobra.Command, args []string)) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.postAutoUnMarshal = post
 	}
+}
 
-	v := reflect.ValueOf(v0).Elem()
-	t := v.Type()
-	flagSet := getFlagSet(cmd, cfg)
-	for i := 0; i < v.NumField(); i++ {
-		fValue := v.Field(i)
-		field := t.Field(i)
-		tag := parseTag(field, cfg)
-		if tag == nil {
-			continue
-		}
-		switch fValue.Kind() {
-		case reflect.String:
-			flagSet.StringVarP(fValue.Addr().Interface().(*string), tag.Name, tag.Short, tag.Default, tag.Desc)
-		case reflect.Bool:
-			flagSet.BoolVarP(fValue.Addr().Interface().(*bool), tag.Name, tag.Short, stringx.ToBool(tag.Default), tag.Desc)
-		case reflect.Float32:
-			flagSet.Float32VarP(fValue.Addr().Interface().(*float32), tag.Name, tag.Short, stringx.Atof[float32](tag.Default), tag.Desc)
-		case reflect.Float64:
-			flagSet.Float64VarP(fValue.Addr().Interface().(*float64), tag.Name, tag.Short, stringx.Atof[float64](tag.Default), tag.Desc)
-		case reflect.Int:
-			flagSet.IntVarP(fValue.Addr().Interface().(*int), tag.Name, tag.Short, stringx.Atoi[int](tag.Default), tag.Desc)
-		case reflect.Int32:
-			flagSet.Int32VarP(fValue.Addr().Interface().(*int32), tag.Name, tag.Short, stringx.Atoi[int32](tag.Default), tag.Desc)
-		case reflect.Int64:
-			bindInt64(flagSet, fValue, tag)
-		case reflect.Slice:
-			if err := bindSlice(flagSet, fValue, field, tag); err != nil {
-				return err
-			}
-		case reflect.Struct:
-			if err := bindStruct(cmd, fValue, field, cfg); err != nil {
-				return err
-			}
-		case reflect.Pointer:
-			if err := bindPointer(cmd, fValue, field, cfg); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("unsupported type: %s|%s", field.Name, fValue.Kind())
-		}
+// WithPostAutoUnMarshalEOption executed after `UnmarshalFlags`, the error is only returned by `cmd.PreRunE`
+func WithPostAutoUnMarshalEOption(postE func(cmd *cobra.Command, args []string) error) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.postAutoUnMarshalE = postE
 	}
-	return nil
 }
 
-func readFlags(v0 builtin.Any, cfg *FlagConfig) error {
-	v := reflect.ValueOf(v0).Elem()
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		fValue := v.Field(i)
-		field := t.Field(i)
-		tag := getTag(field, cfg)
-		if tag == nil {
-			continue
-		}
-		switch fValue.Kind() {
-		case reflect.String:
-			fValue.Set(reflect.ValueOf(viper.GetString(tag.Name)))
-		case reflect.Bool:
-			fValue.Set(reflect.ValueOf(viper.GetBool(tag.Name)))
-		case reflect.Float32:
-			fValue.Set(reflect.ValueOf(float32(viper.GetFloat64(tag.Name))))
-		case reflect.Float64:
-			fValue.Set(reflect.ValueOf(viper.GetFloat64(tag.Name)))
-		case reflect.Int:
-			fValue.Set(reflect.ValueOf(viper.GetInt(tag.Name)))
-		case reflect.Int32:
-			fValue.Set(reflect.ValueOf(viper.GetInt32(tag.Name)))
-		case reflect.Int64:
-			readInt64(fValue, tag)
-		case reflect.Slice:
-			if err := readSlice(fValue, tag); err != nil {
-				return err
-			}
-		case reflect.Struct:
-			if err := readStruct(fValue, field, cfg); err != nil {
-				return err
-			}
-		case reflect.Pointer:
-			if err := readPointer(fValue, field, cfg); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("unsupported type: %s|%s", field.Name, fValue.Kind())
-		}
+// WithPersistentPreRunEOption set `cmd.PersistentPreRunE` to auto unmarshal then fn,
+// so that the auto unmarshal applies to the command and all subcommands, the existing persistent hook runs first
+func WithPersistentPreRunEOption(fn func(cmd *cobra.Command, args []string) error) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.persistentPreRunE = fn
 	}
-	return nil
 }
 
-/////////////////////////////////////////////////////// cast ///////////////////////////////////////////////////////
+// WithPersistentPreRunOption set `cmd.PersistentPreRun` to auto unmarshal then fn, the errors are ignored,
+// the existing persistent hook runs first
+func WithPersistentPreRunOption(fn func(cmd *cobra.Command, args []string)) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.persistentPreRun = fn
+	}
+}
 
-// alias
-type decoderConfigOption = viper.DecoderConfigOption
+// WithFlagTransformerChainOption transform the values read by `ReadFlags` in sequence before setting the fields
+// Multiple calls append to the chain
+func WithFlagTransformerChainOption(transformers ...FlagTransformer) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.transformers = append(cfg.transformers, transformers...)
+	}
+}
 
-func castConfigOptions(cfg *FlagConfig) []decoderConfigOption {
-	return []decoderConfigOption{
-		withSquashOption(true),
-		withTagNameOption(cfg.tagName),
-		withIgnoreUntaggedFieldsOption(cfg.ignoreUntaggedFields),
+// WithStrictNilPointersOption return an error for nil struct pointer fields instead of allocating them
+func WithStrictNilPointersOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.strictNilPointers = true
+	}
+}
+
+// WithViperOption use the viper instance instead of the global one
+func WithViperOption(v *viper.Viper) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.viper = v
+	}
+}
+
+// WithPrefixOption prepend the namespace to all flag names, e.g. `WithPrefixOption("server")` -> --server.port
+// `UnmarshalFlags` decodes the viper keys under the namespace, e.g. `server.port` -> Port
+func WithPrefixOption(prefix string) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.prefix = prefix
+	}
+}
+
+// WithPrefixSepOption the separator between the prefix and the flag name, default is "."
+// e.g. `WithPrefixSepOption("-")` -> --server-port
+func WithPrefixSepOption(sep string) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.prefixSep = sep
+	}
+}
+
+// WithNestedSepOption the separator between the nested struct and the field names in non-squash mode, default is "."
+// e.g. `WithNestedSepOption("_")` -> --server_port
+// The viper keys of `ReadFlags` use the same names,
+// `UnmarshalFlags` needs a viper with the same key delimiter, see `viper.KeyDelimiter`
+func WithNestedSepOption(sep string) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.nestedSep = sep
+	}
+}
+
+// WithNameTransformerOption transform the full flag name (with prefixes) before registration
+// Multiple calls are applied in order
+func WithNameTransformerOption(fn func(string) string) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.nameTransformers = append(cfg.nameTransformers, fn)
+	}
+}
+
+// WithDecodeHookOption add the custom hook to `UnmarshalFlags`, multiple calls are composed in order
+func WithDecodeHookOption(hook mapstructure.DecodeHookFunc) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.decodeHooks = append(cfg.decodeHooks, hook)
+	}
+}
+
+// WithEnvPrefixOption set the environment variable prefix of viper, "-" and "." in the key are replaced by "_"
+// e.g. `WithEnvPrefixOption("MYAPP")` -> --log-level reads MYAPP_LOG_LEVEL (with `WithAutoEnvOption`)
+func WithEnvPrefixOption(prefix string) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.envPrefix = prefix
+	}
+}
+
+// WithAutoEnvOption read all flags from the environment variables, see `viper.AutomaticEnv`
+func WithAutoEnvOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.autoEnv = true
+	}
+}
+
+// WithConfigFileOption load the config file into viper before the auto unmarshal, a missing file is logged as a warning
+// fileType is optional, default is the extension of the file
+func WithConfigFileOption(path, fileType string) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.configFile = path
+		cfg.configType = fileType
+	}
+}
+
+// WithFromFileOption load the config file into viper before the flags are registered,
+// the file values are the defaults overridden by the env vars and the flags, a missing file is logged as a warning
+func WithFromFileOption(path string) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.fromFile = path
+	}
+}
+
+// WithErrorHandlerOption report every field error to fn and continue binding the rest fields,
+// `BindFlags` returns all the errors joined
+func WithErrorHandlerOption(fn func(error)) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.errorHandler = fn
+	}
+}
+
+// WithValidationOption validate the struct after `ReadFlags` and `UnmarshalFlags` populate it,
+// fn receives the struct pointer, e.g. `WithValidationOption(validator.New().Struct)`
+func WithValidationOption(fn func(builtin.Any) error) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.validate = fn
+	}
+}
+
+// WithCamelToKebabOption the fields without explicit name use kebab-case flag names,
+// e.g. DatabaseURL -> --database-url, HTTPSPort -> --https-port
+func WithCamelToKebabOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.camelToKebab = true
+		cfg.fieldNameFunc = stringx.ToKebab
+	}
+}
+
+// WithCamelToSnakeOption the fields without explicit name use snake_case flag names,
+// e.g. DatabaseURL -> --database_url, HTTPSPort -> --https_port
+// It cannot be used with `WithCamelToKebabOption`
+func WithCamelToSnakeOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.camelToSnake = true
+		cfg.fieldNameFunc = stringx.ToSnake
+	}
+}
+
+// WithDefaultProviderOption the provider receives the flag name and returns (defaultValue, found),
+// the found value overrides the `default` label of the field,
+// e.g. WithDefaultProviderOption(func(n string) (string, bool) { return os.LookupEnv("DEFAULT_" + n) })
+func WithDefaultProviderOption(provider func(string) (string, bool)) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.defaultProvider = provider
+	}
+}
+
+// WithStrictModeOption `UnmarshalFlags` returns an error if the viper keys have no corresponding field,
+// e.g. the typos in the config file
+func WithStrictModeOption(strict bool) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.strict = strict
+	}
+}
+
+// WithWeaklyTypedInputOption `UnmarshalFlags` converts the mismatched types, e.g. the bare integer in the config file to string,
+// it is enabled by default, same as viper
+func WithWeaklyTypedInputOption(weak bool) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.weaklyTypedInput = weak
+	}
+}
+
+// WithIncludeHiddenOption the generated docs include the hidden flags, see `GenerateMarkdownDocs`
+func WithIncludeHiddenOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.includeHidden = true
+	}
+}
+
+// WithNoCacheOption parse the tags without the cache, e.g. in tests
+func WithNoCacheOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.noCache = true
+	}
+}
+
+// WithIgnoreTypeOption skip the fields of the types silently instead of "unsupported type" errors,
+// e.g. WithIgnoreTypeOption(reflect.TypeOf((*zap.Logger)(nil))), multiple calls accumulate
+func WithIgnoreTypeOption(types ...reflect.Type) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.ignoreTypes = append(cfg.ignoreTypes, types...)
+	}
+}
+
+// WithIgnoreKindOption skip the fields of the kinds silently, e.g. WithIgnoreKindOption(reflect.Chan, reflect.Func)
+// multiple calls accumulate
+func WithIgnoreKindOption(kinds ...reflect.Kind) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.ignoreKinds = append(cfg.ignoreKinds, kinds...)
+	}
+}
+
+// WithFieldFilterOption bind the field only if filter returns true, the struct field is skipped with its fields
+// e.g. bind the explicitly tagged fields only:
+//
+//	WithFieldFilterOption(func(f reflect.StructField) bool { _, ok := f.Tag.Lookup("flag"); return ok })
+func WithFieldFilterOption(filter func(reflect.StructField) bool) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.fieldFilter = filter
+	}
+}
+
+// WithAnnotationOption set the annotation of all bound flags, multiple calls with different keys accumulate
+// e.g. WithAnnotationOption("myapp.configurable", []string{"true"}), see the `annotation` label for a single flag
+func WithAnnotationOption(key string, values []string) FlagOption {
+	return func(cfg *FlagConfig) {
+		if cfg.annotations == nil {
+			cfg.annotations = make(map[string][]string)
+		}
+		cfg.annotations[key] = values
+	}
+}
+
+// WithAtLeastOneOption at least one of the flags must be set, e.g. WithAtLeastOneOption("file", "stdin", "url")
+// multiple calls register multiple independent groups
+func WithAtLeastOneOption(flagNames ...string) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.oneRequired = cfg.oneRequired.add(flagNames...)
+	}
+}
+
+// WithMutuallyExclusiveOption at most one of the flags can be set, e.g. WithMutuallyExclusiveOption("json", "yaml", "toml")
+// multiple calls register multiple independent groups, see the `exclusive-with` label for the per-field groups
+//
+// Deprecated: use WithMarkFlagsMutuallyExclusiveOption, which registers multiple groups with one call
+func WithMutuallyExclusiveOption(flagNames ...string) FlagOption {
+	return WithMarkFlagsMutuallyExclusiveOption(flagNames)
+}
+
+// WithMarkFlagsMutuallyExclusiveOption each group is an independent mutual exclusion group,
+// e.g. WithMarkFlagsMutuallyExclusiveOption([]string{"json", "yaml"}, []string{"verbose", "quiet"})
+func WithMarkFlagsMutuallyExclusiveOption(groups ...[]string) FlagOption {
+	return func(cfg *FlagConfig) {
+		for _, group := range groups {
+			cfg.mutuallyExclusive = cfg.mutuallyExclusive.add(group...)
+		}
+	}
+}
+
+// WithRequiredTogetherOption the flags must be set together, e.g. WithRequiredTogetherOption("tls-cert", "tls-key", "tls-ca")
+// multiple calls register multiple independent groups, the same set declared by the `required-with` label is marked once
+func WithRequiredTogetherOption(flagNames ...string) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.requiredTogether = cfg.requiredTogether.add(flagNames...)
+	}
+}
+
+// WithNoViperOption the flags are not bound to viper, the fields are set by pflag directly
+// `ReadFlags` and `UnmarshalFlags` return an error with this option
+func WithNoViperOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.noViper = true
+	}
+}
+
+// WithDisableViperBindPFlagsOption skip `viper.BindPFlags` for the applications binding the viper keys by themselves,
+// unlike `WithNoViperOption`, `ReadFlags` and `UnmarshalFlags` are unaffected
+func WithDisableViperBindPFlagsOption() FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.disableViperBind = true
+	}
+}
+
+// WithInheritPersistentFlagsOption bind the persistent flags of the parent command to viper too,
+// so that `ReadFlags` and `UnmarshalFlags` of the child struct can read the parent flags
+func WithInheritPersistentFlagsOption(parent *cobra.Command) FlagOption {
+	return func(cfg *FlagConfig) {
+		cfg.inheritParents = append(cfg.inheritParents, parent)
+	}
+}
+
+var errNoViper = errors.New("viper is disabled by WithNoViperOption, the fields are set by pflag directly")
+
+/////////////////////////////////////////////////////// implement ///////////////////////////////////////////////////////
+
+func bindFlags(cmd *cobra.Command, v0 builtin.Any, cfg *FlagConfig) error {
+	if err := checkStructPointer("BindFlags", v0); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(v0).Elem()
+	t := v.Type()
+	defer visitType(t, cfg)()
+	tags := getFieldTags(t, cfg)
+	for i := 0; i < v.NumField(); i++ {
+		fValue := v.Field(i)
+		field := t.Field(i)
+		tag := parseTag(field, tags[i], cfg)
+		if tag == nil {
+			logMessage(cfg, LogLevelDebug, "skip field", "field", field.Name, "type", field.Type)
+			continue
+		}
+
+		path := cfg.fieldPath
+		cfg.fieldPath = append(path[:len(path):len(path)], field.Name)
+		var err error
+		switch {
+		case isStructSlice(field.Type):
+			err = bindStructSlice(cmd, fValue, tag, cfg)
+		case isStepInto(field) && fValue.Kind() == reflect.Struct:
+			err = bindStruct(cmd, fValue, field, tag, cfg)
+		case fValue.Kind() == reflect.Pointer && !isValueType(field.Type):
+			err = bindPointer(cmd, fValue, field, tag, cfg)
+		default:
+			err = bindField(cmd, fValue, field, tag, cfg)
+		}
+		err = wrapFieldError(cfg.fieldPath, err)
+		cfg.fieldPath = path
+		if err != nil {
+			if cfg.errorHandler == nil {
+				return err
+			}
+			// continue with the next field, the errors are joined by the caller
+			cfg.errorHandler(err)
+			cfg.errs = append(cfg.errs, err)
+		}
+	}
+	return nil
+}
+
+// bindField register and mark the flag of non-struct field
+func bindField(cmd *cobra.Command, fValue reflect.Value, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	flagSet := getFlagSet(cmd, cfg)
+	if tag.Persistent {
+		flagSet = cmd.PersistentFlags()
+	}
+	if err := checkFlagDefined(cmd, flagSet, field, tag); err != nil {
+		return err
+	}
+	if err := bindValue(flagSet, fValue, field, tag); err != nil {
+		return err
+	}
+	logMessage(cfg, LogLevelDebug, "bind flag", "field", strings.Join(cfg.fieldPath, "."), "flag", tag.Name, "type", fValue.Type())
+	return markFlag(flagSet, field, tag, cfg)
+}
+
+// bindValue register a flag for non-struct field
+func bindValue(flagSet *flag.FlagSet, fValue reflect.Value, field reflect.StructField, tag *tagData) error {
+	if binder, ok := lookupTypeBinder(fValue.Type()); ok {
+		return bindCustom(flagSet, binder, fValue, field, tag)
+	}
+	if isPrimitivePointer(fValue.Type()) {
+		return bindPointerValue(flagSet, fValue, field, tag)
+	}
+	// the defaults below are converted without error, e.g. `stringx.Atoi`
+	if len(tag.Default) > 0 {
+		if _, err := parseDefault(fValue.Type(), tag); err != nil {
+			return err
+		}
+	}
+	if fValue.Type() == timeType {
+		return bindTime(flagSet, fValue, tag)
+	}
+	if isHexInt(fValue.Type(), tag) {
+		bindHexInt(flagSet, fValue, tag)
+		return nil
+	}
+	if value, ok := flagValue(fValue); ok {
+		return bindFlagValue(flagSet, value, field, tag)
+	}
+	if text, ok := textUnmarshaler(fValue); ok {
+		return bindText(flagSet, text, field, tag)
+	}
+
+	if tag.Count && fValue.Type() != reflect.TypeOf(0) {
+		return fmt.Errorf("field `%s` label `%s` requires int, got %s", field.Name, TagLabelCount, fValue.Type())
+	}
+
+	switch fValue.Kind() {
+	case reflect.String:
+		flagSet.StringVarP(fValue.Addr().Interface().(*string), tag.Name, tag.Short, tag.Default, tag.Desc)
+	case reflect.Bool:
+		flagSet.BoolVarP(fValue.Addr().Interface().(*bool), tag.Name, tag.Short, stringx.ToBool(tag.Default), tag.Desc)
+	case reflect.Float32:
+		flagSet.Float32VarP(fValue.Addr().Interface().(*float32), tag.Name, tag.Short, stringx.Atof[float32](tag.Default), tag.Desc)
+	case reflect.Float64:
+		flagSet.Float64VarP(fValue.Addr().Interface().(*float64), tag.Name, tag.Short, stringx.Atof[float64](tag.Default), tag.Desc)
+	case reflect.Int:
+		bindInt(flagSet, fValue, tag)
+	case reflect.Int32:
+		flagSet.Int32VarP(fValue.Addr().Interface().(*int32), tag.Name, tag.Short, stringx.Atoi[int32](tag.Default), tag.Desc)
+	case reflect.Int64:
+		bindInt64(flagSet, fValue, tag)
+	case reflect.Slice:
+		return bindSlice(flagSet, fValue, field, tag)
+	case reflect.Map:
+		return bindMap(flagSet, fValue, tag)
+	default:
+		return fmt.Errorf("unsupported type: %s", fValue.Type())
+	}
+	return nil
+}
+
+// markFlag apply the labels of the tag to the registered flag
+func markFlag(flagSet *flag.FlagSet, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	if tag.Required {
+		if len(tag.Default) > 0 {
+			return fmt.Errorf("field `%s` is required, the default value %q is redundant", field.Name, tag.Default)
+		}
+		if err := cobra.MarkFlagRequired(flagSet, tag.Name); err != nil {
+			return err
+		}
+	}
+	if tag.Hidden {
+		if err := flagSet.MarkHidden(tag.Name); err != nil {
+			return err
+		}
+	}
+	if len(tag.Deprecated) > 0 {
+		if err := flagSet.MarkDeprecated(tag.Name, tag.Deprecated); err != nil {
+			return err
+		}
+	}
+	if len(tag.OneOf) > 0 {
+		if err := markOneOf(flagSet.Lookup(tag.Name), field, tag); err != nil {
+			return err
+		}
+	}
+	if len(tag.Min) > 0 || len(tag.Max) > 0 {
+		if err := markRange(flagSet.Lookup(tag.Name), field, tag); err != nil {
+			return err
+		}
+	}
+	if len(tag.Pattern) > 0 {
+		if err := markPattern(flagSet.Lookup(tag.Name), field, tag); err != nil {
+			return err
+		}
+	}
+	if tag.Filename && tag.Dirname {
+		return fmt.Errorf("field `%s` labels `%s` and `%s` cannot be used together", field.Name, TagLabelFilename, TagLabelDirname)
+	}
+	if tag.Filename {
+		if err := cobra.MarkFlagFilename(flagSet, tag.Name, tag.FileExts...); err != nil {
+			return err
+		}
+	}
+	if tag.Dirname {
+		if err := cobra.MarkFlagDirname(flagSet, tag.Name); err != nil {
+			return err
+		}
+	}
+	// the annotations of the tag override the ones of `WithAnnotationOption` with the same key
+	for _, annotations := range []map[string][]string{cfg.annotations, tag.Annotations} {
+		for key, values := range annotations {
+			if err := flagSet.SetAnnotation(tag.Name, key, values); err != nil {
+				return err
+			}
+		}
+	}
+	if len(tag.RequiredWith) > 0 {
+		cfg.requiredTogether = cfg.requiredTogether.add(append([]string{tag.Name}, tag.RequiredWith...)...)
+	}
+	if len(tag.ExclusiveWith) > 0 {
+		cfg.mutuallyExclusive = cfg.mutuallyExclusive.add(append([]string{tag.Name}, tag.ExclusiveWith...)...)
+	}
+	// the labels of viper are ignored by `WithNoViperOption`, so that the global viper is not touched
+	if cfg.noViper {
+		return nil
+	}
+	// the flag overrides the value of the viper key
+	if len(tag.ViperKey) > 0 {
+		if err := getViper(cfg).BindPFlag(tag.ViperKey, flagSet.Lookup(tag.Name)); err != nil {
+			return err
+		}
+	}
+	if len(tag.Env) > 0 {
+		if err := getViper(cfg).BindEnv(tag.key(), tag.Env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFlags(v0 builtin.Any, cfg *FlagConfig) error {
+	if err := checkStructPointer("ReadFlags", v0); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(v0).Elem()
+	t := v.Type()
+	defer visitType(t, cfg)()
+	tags := getFieldTags(t, cfg)
+	for i := 0; i < v.NumField(); i++ {
+		fValue := v.Field(i)
+		field := t.Field(i)
+		tag := parseTag(field, tags[i], cfg)
+		if tag == nil {
+			continue
+		}
+
+		var err error
+		switch {
+		case isStructSlice(field.Type):
+			err = readStructSlice(fValue, tag, cfg)
+		case isStepInto(field) && fValue.Kind() == reflect.Struct:
+			err = readStruct(fValue, field, tag, cfg)
+		case fValue.Kind() == reflect.Pointer && !isValueType(field.Type):
+			err = readPointer(fValue, field, tag, cfg)
+		default:
+			err = readValue(fValue, field, tag, cfg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readValue read the value of non-struct field from viper
+func readValue(fValue reflect.Value, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	vp := getViper(cfg)
+	if binder, ok := lookupTypeBinder(fValue.Type()); ok {
+		return readCustom(vp, binder, fValue, field, tag, cfg)
+	}
+	if fValue.Type() == timeType {
+		value, err := readTime(vp, tag)
+		if err != nil {
+			return fmt.Errorf("field `%s`: %w", field.Name, err)
+		}
+		return setValue(fValue, field, tag, value, cfg)
+	}
+	if isHexInt(fValue.Type(), tag) {
+		value, err := readHexInt(vp, fValue, tag)
+		if err != nil {
+			return fmt.Errorf("field `%s`: %w", field.Name, err)
+		}
+		return setValue(fValue, field, tag, value, cfg)
+	}
+	if value, ok := flagValue(fValue); ok {
+		return readFlagValue(value, field, tag, cfg)
+	}
+	if text, ok := textUnmarshaler(fValue); ok {
+		return readText(text, field, tag, cfg)
+	}
+
+	var value builtin.Any
+	switch fValue.Kind() {
+	case reflect.String:
+		value = vp.GetString(tag.key())
+	case reflect.Bool:
+		value = vp.GetBool(tag.key())
+	case reflect.Float32:
+		value = float32(vp.GetFloat64(tag.key()))
+	case reflect.Float64:
+		value = vp.GetFloat64(tag.key())
+	case reflect.Int:
+		value = vp.GetInt(tag.key())
+	case reflect.Int32:
+		value = vp.GetInt32(tag.key())
+	case reflect.Int64:
+		value = readInt64(vp, fValue, tag)
+	case reflect.Slice:
+		var err error
+		if value, err = readSlice(vp, fValue, tag); err != nil {
+			return err
+		}
+	case reflect.Map:
+		var err error
+		if value, err = readMap(vp, fValue, tag); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported type: %s|%s", field.Name, fValue.Kind())
+	}
+
+	return setValue(fValue, field, tag, value, cfg)
+}
+
+// setValue pass the value through the transformer chain and set it to the field
+func setValue(fValue reflect.Value, field reflect.StructField, tag *tagData, value builtin.Any, cfg *FlagConfig) error {
+	value, err := transform(fValue.Kind(), field, tag, value, cfg)
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(value)
+	switch {
+	case !rv.IsValid():
+		fValue.Set(reflect.Zero(fValue.Type()))
+	case rv.Type().AssignableTo(fValue.Type()):
+		fValue.Set(rv)
+	case rv.Kind() == fValue.Kind() && rv.Type().ConvertibleTo(fValue.Type()):
+		fValue.Set(rv.Convert(fValue.Type()))
+	default:
+		return fmt.Errorf("field `%s` cannot be set with %T", field.Name, value)
+	}
+	return nil
+}
+
+func transform(kind reflect.Kind, field reflect.StructField, tag *tagData, value builtin.Any, cfg *FlagConfig) (builtin.Any, error) {
+	for _, transformer := range cfg.transformers {
+		v, err := transformer.Transform(tag.Name, kind.String(), value)
+		if err != nil {
+			return nil, fmt.Errorf("transform %s: %w", field.Name, err)
+		}
+		value = v
+	}
+	return value, nil
+}
+
+/////////////////////////////////////////////////////// cast ///////////////////////////////////////////////////////
+
+// alias
+type decoderConfigOption = viper.DecoderConfigOption
+
+func castConfigOptions(cfg *FlagConfig) []decoderConfigOption {
+	opts := []decoderConfigOption{
+		withSquashOption(true),
+		withTagNameOption(cfg.tagName),
+		withIgnoreUntaggedFieldsOption(cfg.ignoreUntaggedFields),
+		withErrorUnusedOption(cfg.strict),
+		withWeaklyTypedInputOption(cfg.weaklyTypedInput),
+		withDecodeHookOption(cfg.decodeHooks...),
+		withMatchNameOption(cfg.fieldNameFunc, cfg.normalize),
+	}
+	return opts
+}
+
+func withSquashOption(squash bool) decoderConfigOption {
+	return func(config *mapstructure.DecoderConfig) {
+		config.Squash = squash
+	}
+}
+
+func withTagNameOption(tag string) decoderConfigOption {
+	return func(config *mapstructure.DecoderConfig) {
+		config.TagName = tag
+	}
+}
+
+// ignore undefined tag fields
+func withIgnoreUntaggedFieldsOption(ignore bool) decoderConfigOption {
+	return func(config *mapstructure.DecoderConfig) {
+		config.IgnoreUntaggedFields = ignore
+	}
+}
+
+// error on the keys without corresponding field
+func withErrorUnusedOption(strict bool) decoderConfigOption {
+	return func(config *mapstructure.DecoderConfig) {
+		config.ErrorUnused = strict
+	}
+}
+
+// convert the mismatched types, e.g. int to string
+func withWeaklyTypedInputOption(weak bool) decoderConfigOption {
+	return func(config *mapstructure.DecoderConfig) {
+		config.WeaklyTypedInput = weak
+	}
+}
+
+// the keys of the untagged fields are named by fieldNameFunc, e.g. DatabaseURL -> database-url of `WithCamelToKebabOption`
+// the keys are normalized by `WithNormalizeOption` as the flag names, e.g. log_level -> log-level
+func withMatchNameOption(fieldNameFunc func(string) string, normalize func(f *flag.FlagSet, name string) flag.NormalizedName) decoderConfigOption {
+	return func(config *mapstructure.DecoderConfig) {
+		if fieldNameFunc == nil && normalize == nil {
+			return
+		}
+		// fieldName is the tag name if set, otherwise the go field name
+		config.MatchName = func(mapKey, fieldName string) bool {
+			if strings.EqualFold(mapKey, fieldName) {
+				return true
+			}
+			if fieldNameFunc != nil && mapKey == normalizeName(normalize, fieldNameFunc(fieldName)) {
+				return true
+			}
+			return normalize != nil && strings.EqualFold(mapKey, normalizeName(normalize, fieldName))
+		}
+	}
+}
+
+// the hooks are composed after the default hooks of viper and the map hook
+func withDecodeHookOption(hooks ...mapstructure.DecodeHookFunc) decoderConfigOption {
+	return func(config *mapstructure.DecoderConfig) {
+		config.DecodeHook = mapstructure.ComposeDecodeHookFunc(append([]mapstructure.DecodeHookFunc{
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+			stringToBoolMapHookFunc(),
+			stringToDurationHookFunc(),
+		}, hooks...)...)
+	}
+}
+
+// the map[string]bool flag is a string in viper, e.g. `cache,debug=false`
+// the map[string]interface{} flag is the JSON string, e.g. `{"key":"value"}`
+func stringToBoolMapHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data builtin.Any) (builtin.Any, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+		switch t {
+		case reflect.TypeOf(map[string]bool{}):
+			return parseBoolMap(data.(string), TagLabelSep)
+		case jsonMapType:
+			return parseJSONMap(data.(string))
+		default:
+			return data, nil
+		}
+	}
+}
+
+/////////////////////////////////////////////////////// helper ///////////////////////////////////////////////////////
+
+// bind the fields of the structs to the command and mark the flag groups
+func bindCommand(cmd *cobra.Command, cfg *FlagConfig, structs ...builtin.Any) error {
+	if len(cfg.envPrefix) > 0 && !cfg.noViper {
+		getViper(cfg).SetEnvPrefix(cfg.envPrefix)
+		getViper(cfg).SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
+	}
+	for _, v0 := range structs {
+		if err := bindFlags(cmd, v0, cfg); err != nil {
+			return err
+		}
+	}
+	if err := errors.Join(cfg.errs...); err != nil {
+		return err
+	}
+	return markFlagGroups(cmd, cfg)
+}
+
+// configureCommand apply the command options after the flags are registered
+func configureCommand(cmd *cobra.Command, cfg *FlagConfig) error {
+	if cfg.args != nil {
+		cmd.Args = cfg.args
+	}
+	if cfg.silenceUsage != nil {
+		cmd.SilenceUsage = *cfg.silenceUsage
+	}
+	if cfg.silenceErrors != nil {
+		cmd.SilenceErrors = *cfg.silenceErrors
+	}
+	if cfg.disableSort {
+		getFlagSet(cmd, cfg).SortFlags = false
+		cmd.Flags().SortFlags = false
+		cmd.PersistentFlags().SortFlags = false
+	}
+	for name, fn := range cfg.completionFuncs {
+		if err := cmd.RegisterFlagCompletionFunc(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeName the name of `WithNormalizeOption`, the flag set passed to the normalize func is nil
+func normalizeName(normalize func(f *flag.FlagSet, name string) flag.NormalizedName, name string) string {
+	if normalize == nil {
+		return name
+	}
+	return string(normalize(nil, name))
+}
+
+// setNormalizeFunc the flags are normalized by `getTag` before registration, so pflag does not rename them,
+// the other names are normalized by pflag, e.g. `--log_level` on the command line
+func setNormalizeFunc(cfg *FlagConfig, flagSets ...*flag.FlagSet) {
+	if cfg.normalize == nil {
+		return
+	}
+	for _, flagSet := range flagSets {
+		flagSet.SetNormalizeFunc(cfg.normalize)
+	}
+}
+
+// logMessage no-op without `WithLoggerOption`
+func logMessage(cfg *FlagConfig, level, msg string, fields ...builtin.Any) {
+	if cfg.logger != nil {
+		cfg.logger(level, msg, fields...)
+	}
+}
+
+// fieldError the error with the full field path, e.g. Server.Database.Port: unsupported type: int8
+type fieldError struct {
+	path string
+	err  error
+}
+
+func (e *fieldError) Error() string {
+	return e.path + ": " + e.err.Error()
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// wrapFieldError the error of the nested field is wrapped once by the innermost struct
+func wrapFieldError(path []string, err error) error {
+	var fe *fieldError
+	if err == nil || errors.As(err, &fe) {
+		return err
+	}
+	return &fieldError{path: strings.Join(path, "."), err: err}
+}
+
+// bindPFlags bind the flag sets to viper, e.g. `cmd.Flags()` and `cmd.PersistentFlags()` of the `persistent` label
+func bindPFlags(cfg *FlagConfig, flagSets ...*flag.FlagSet) error {
+	if cfg.disableViperBind {
+		return nil
+	}
+	for _, flagSet := range flagSets {
+		if err := getViper(cfg).BindPFlags(flagSet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setViperDefaults the defaults are parsed as the field types, e.g. `default:1s` -> time.Second
+func setViperDefaults(cfg *FlagConfig, structs ...builtin.Any) error {
+	if !cfg.setViperDefaults {
+		return nil
+	}
+
+	vp := getViper(cfg)
+	for _, v0 := range structs {
+		err := walkFlags(reflect.ValueOf(v0).Elem(), "", cfg, false, func(_ reflect.Value, info *FlagInfo) error {
+			if len(info.Default) == 0 {
+				return nil
+			}
+			value, err := parseDefault(info.typ, info.tag)
+			if err != nil {
+				return &fieldError{path: info.FieldPath, err: err}
+			}
+			vp.SetDefault(info.tag.key(), value.Interface())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkStructPointer the common mistake is passing the struct value without `&`, fn is the public entry point, e.g. BindFlags
+func checkStructPointer(fn string, v0 builtin.Any) error {
+	v := reflect.ValueOf(v0)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("%s: v0 must be a non-nil pointer to a struct, got %T; did you mean &config?", fn, v0)
+	}
+	if v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%s: v0 must point to a struct, got pointer to %s", fn, v.Elem().Kind())
+	}
+	return nil
+}
+
+// set  auto marshal function
+func autoMarshalOption(cmd *cobra.Command, v0 builtin.Any, opts ...FlagOption) {
+	// the options are checked by the caller
+	cfg, _ := defaultFlagConfig(opts...)
+
+	// applied to the command and all subcommands, the existing persistent hook of the command runs first
+	if cfg.persistentPreRunE != nil {
+		fn, handler := cfg.persistentPreRunE, persistentPreRunE(cmd)
+		cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+			if handler != nil {
+				if err := handler(cmd, args); err != nil {
+					return err
+				}
+			}
+			if err := autoUnMarshalE(cmd, args, v0, cfg, opts...); err != nil {
+				return err
+			}
+			return fn(cmd, args)
+		}
+		return
+	}
+	if cfg.persistentPreRun != nil {
+		fn := cfg.persistentPreRun
+		// cobra skips `PersistentPreRun` if `PersistentPreRunE` is set
+		if handler := cmd.PersistentPreRunE; handler != nil {
+			cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+				if err := handler(cmd, args); err != nil {
+					return err
+				}
+				autoUnMarshal(cmd, args, v0, cfg, opts...)
+				fn(cmd, args)
+				return nil
+			}
+			return
+		}
+		handler := cmd.PersistentPreRun
+		cmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+			if handler != nil {
+				handler(cmd, args)
+			}
+			autoUnMarshal(cmd, args, v0, cfg, opts...)
+			fn(cmd, args)
+		}
+		return
+	}
+
+	if !cfg.autoUnMarshalFlag {
+		return
+	}
+
+	if cmd.PreRun != nil {
+		handler := cmd.PreRun
+		cmd.PreRun = func(cmd *cobra.Command, args []string) {
+			autoUnMarshal(cmd, args, v0, cfg, opts...)
+			handler(cmd, args)
+		}
+	} else if cmd.PreRunE != nil {
+		handler := cmd.PreRunE
+		cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+			if err := autoUnMarshalE(cmd, args, v0, cfg, opts...); err != nil {
+				return err
+			}
+			return handler(cmd, args)
+		}
+	} else {
+		// the command without pre run hooks, e.g. only `cmd.Run`
+		cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+			return autoUnMarshalE(cmd, args, v0, cfg, opts...)
+		}
+	}
+}
+
+// persistentPreRunE the persistent hook cobra runs for the command, `PersistentPreRunE` wins over `PersistentPreRun`
+func persistentPreRunE(cmd *cobra.Command) func(cmd *cobra.Command, args []string) error {
+	switch {
+	case cmd.PersistentPreRunE != nil:
+		return cmd.PersistentPreRunE
+	case cmd.PersistentPreRun != nil:
+		handler := cmd.PersistentPreRun
+		return func(cmd *cobra.Command, args []string) error {
+			handler(cmd, args)
+			return nil
+		}
+	default:
+		return nil
+	}
+}
+
+// autoUnMarshal the errors are logged and ignored, used by `cmd.PreRun`
+func autoUnMarshal(cmd *cobra.Command, args []string, v0 builtin.Any, cfg *FlagConfig, opts ...FlagOption) {
+	_ = runAutoUnMarshal(cmd, args, v0, cfg, func(msg string, err error) error {
+		logMessage(cfg, LogLevelError, msg, "command", cmd.Name(), "error", err)
+		return nil
+	}, opts...)
+}
+
+// autoUnMarshalE the first error is returned, used by `cmd.PreRunE`
+func autoUnMarshalE(cmd *cobra.Command, args []string, v0 builtin.Any, cfg *FlagConfig, opts ...FlagOption) error {
+	return runAutoUnMarshal(cmd, args, v0, cfg, func(_ string, err error) error {
+		return err
+	}, opts...)
+}
+
+// runAutoUnMarshal run all the configured hooks in order, whichever kind of pre run hook is used:
+// pre, pre E, pre context, the config file, `UnmarshalFlags`, post, post E
+// fail is called with every error, the hooks stop at the first error it returns
+func runAutoUnMarshal(cmd *cobra.Command, args []string, v0 builtin.Any, cfg *FlagConfig, fail func(msg string, err error) error, opts ...FlagOption) error {
+	check := func(msg string, err error) error {
+		if err == nil {
+			return nil
+		}
+		return fail(msg, err)
+	}
+
+	if cfg.preAutoUnMarshal != nil {
+		cfg.preAutoUnMarshal(cmd, args)
+	}
+	if cfg.preAutoUnMarshalE != nil {
+		if err := check("pre auto unmarshal failed", cfg.preAutoUnMarshalE(cmd, args)); err != nil {
+			return err
+		}
+	}
+	if cfg.preAutoUnMarshalContext != nil {
+		if err := check("pre auto unmarshal failed", cfg.preAutoUnMarshalContext(cmd.Context(), cmd, args)); err != nil {
+			return err
+		}
+	}
+	// the fields are already set by pflag without viper
+	if !cfg.noViper {
+		if err := check("read config file failed", readConfigFile(cfg)); err != nil {
+			return err
+		}
+		if err := check("unmarshal flags failed", UnmarshalFlags(v0, opts...)); err != nil {
+			return err
+		}
+	}
+	if cfg.postAutoUnMarshal != nil {
+		cfg.postAutoUnMarshal(cmd, args)
+	}
+	if cfg.postAutoUnMarshalE != nil {
+		return check("post auto unmarshal failed", cfg.postAutoUnMarshalE(cmd, args))
+	}
+	return nil
+}
+
+// unmarshalFlags see `UnmarshalFlags`
+func unmarshalFlags(v0 builtin.Any, cfg *FlagConfig) error {
+	if cfg.noViper {
+		return errNoViper
+	}
+	if err := checkStructPointer("UnmarshalFlags", v0); err != nil {
+		return err
+	}
+
+	var err error
+	switch vp := getViper(cfg); {
+	case len(cfg.prefix) > 0:
+		err = decodeSettings(unprefixedSettings(vp, cfg), v0, cfg)
+	default:
+		err = vp.Unmarshal(v0, castConfigOptions(cfg)...)
+	}
+	if err != nil {
+		return err
+	}
+	return validate(v0, cfg)
+}
+
+// unprefixedSettings the settings under the prefix of `WithPrefixOption`, with the prefix removed from the keys
+// e.g. {"server": {"port": 77}} (--server.port) or {"server-port": 77} (--server-port) -> {"port": 77}
+func unprefixedSettings(vp *viper.Viper, cfg *FlagConfig) map[string]builtin.Any {
+	settings := vp.AllSettings()
+	// the nested keys of viper
+	if cfg.prefixSep == "." {
+		m, _ := settings[cfg.prefix].(map[string]builtin.Any)
+		return m
+	}
+
+	prefix := cfg.prefix + cfg.prefixSep
+	m := make(map[string]builtin.Any)
+	for key, value := range settings {
+		if strings.HasPrefix(key, prefix) {
+			m[strings.TrimPrefix(key, prefix)] = value
+		}
+	}
+	return m
+}
+
+// decodeSettings same as `viper.Unmarshal` with the settings instead of all keys of viper
+func decodeSettings(settings map[string]builtin.Any, v0 builtin.Any, cfg *FlagConfig) error {
+	config := &mapstructure.DecoderConfig{Result: v0}
+	for _, opt := range castConfigOptions(cfg) {
+		opt(config)
+	}
+	decoder, err := mapstructure.NewDecoder(config)
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(settings)
+}
+
+// validate the populated struct with `WithValidationOption`
+func validate(v0 builtin.Any, cfg *FlagConfig) error {
+	if cfg.validate == nil {
+		return nil
+	}
+	return cfg.validate(v0)
+}
+
+// readConfigFile load the file of `WithConfigFileOption`, a missing file is logged and is not an error
+func readConfigFile(cfg *FlagConfig) error {
+	if len(cfg.configFile) == 0 {
+		return nil
+	}
+
+	v := getViper(cfg)
+	v.SetConfigFile(cfg.configFile)
+	if len(cfg.configType) > 0 {
+		v.SetConfigType(cfg.configType)
+	}
+	err := v.ReadInConfig()
+	if errors.Is(err, fs.ErrNotExist) {
+		logMessage(cfg, LogLevelWarn, "config file not found", "file", cfg.configFile)
+		return nil
+	}
+	return err
+}
+
+// readFromFile load the file of `WithFromFileOption`
+func readFromFile(cfg *FlagConfig) error {
+	if len(cfg.fromFile) == 0 || cfg.noViper {
+		return nil
+	}
+
+	v := getViper(cfg)
+	v.SetConfigFile(cfg.fromFile)
+	err := v.ReadInConfig()
+	if errors.Is(err, fs.ErrNotExist) {
+		logMessage(cfg, LogLevelWarn, "config file not found", "file", cfg.fromFile)
+		return nil
+	}
+	return err
+}
+
+func defaultFlagConfig(opts ...FlagOption) (*FlagConfig, error) {
+	cfg := &FlagConfig{
+		tagName:          TagName,
+		tagLabelSep:      TagLabelSep,
+		squash:           true,
+		prefixSep:        ".",
+		weaklyTypedInput: true,
+		nestedSep:        ".",
+		visitedTypes:     make(map[reflect.Type]bool),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.camelToKebab && cfg.camelToSnake {
+		return nil, fmt.Errorf("WithCamelToKebabOption and WithCamelToSnakeOption cannot be used together")
+	}
+	return cfg, nil
+}
+
+// fieldFlagName the flag name of the field without the explicit name, default is the lower case field name
+func fieldFlagName(field reflect.StructField, cfg *FlagConfig) string {
+	if cfg.fieldNameFunc != nil {
+		return cfg.fieldNameFunc(field.Name)
+	}
+	return strings.ToLower(field.Name)
+}
+
+func isStepInto(field reflect.StructField) bool {
+	if isValueType(field.Type) {
+		return false
+	}
+	return field.Type.Kind() == reflect.Struct ||
+		(field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct)
+}
+
+// isStructSlice []struct, except the struct implements `encoding.TextUnmarshaler`
+func isStructSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct && !isValueType(t.Elem())
+}
+
+// isIgnoredType see `WithIgnoreTypeOption` and `WithIgnoreKindOption`
+func isIgnoredType(t reflect.Type, cfg *FlagConfig) bool {
+	for _, ignore := range cfg.ignoreTypes {
+		if t == ignore {
+			return true
+		}
+	}
+	for _, kind := range cfg.ignoreKinds {
+		if t.Kind() == kind {
+			return true
+		}
 	}
+	return false
 }
 
-func withSquashOption(squash bool) decoderConfigOption {
-	return func(config *mapstructure.DecoderConfig) {
-		config.Squash = squash
+// stepInto push the struct name to the prefix stack, the returned function restores the stack of the caller
+// the stack is copied before pushing, so the nested levels never share the backing array
+//
+// add prefix
+// type Base struct {Name string}
+// type Top struct {Base; Level int}
+// skip `Base` field // ignoreUntaggedFields == true
+// --name // ignoreUntaggedFields == false && (cfg.Squash == true || ".squash" in tag)
+// --base.name // ignoreUntaggedFields == false && squash == false
+func stepInto(field reflect.StructField, tag *tagData, cfg *FlagConfig) func() {
+	parent := cfg.parent
+	if !cfg.squash && !tag.squash && isStepInto(field) {
+		cfg.parent = append(parent[:len(parent):len(parent)], tag.origin)
 	}
+	return func() { cfg.parent = parent }
 }
 
-func withTagNameOption(tag string) decoderConfigOption {
-	return func(config *mapstructure.DecoderConfig) {
-		config.TagName = tag
+func getViper(cfg *FlagConfig) *viper.Viper {
+	if cfg.viper != nil {
+		return cfg.viper
 	}
+	return viper.GetViper()
 }
 
-// ignore undefined tag fields
-func withIgnoreUntaggedFieldsOption(ignore bool) decoderConfigOption {
-	return func(config *mapstructure.DecoderConfig) {
-		config.IgnoreUntaggedFields = ignore
+func getFlagSet(cmd *cobra.Command, cfg *FlagConfig) *flag.FlagSet {
+	if cfg.flagSet != nil {
+		return cfg.flagSet
+	}
+	switch cfg.persist {
+	case true:
+		return cmd.PersistentFlags()
+	default:
+		return cmd.Flags()
 	}
 }
 
-/////////////////////////////////////////////////////// helper ///////////////////////////////////////////////////////
-
-// set  auto marshal function
-func autoMarshalOption(cmd *cobra.Command, v0 builtin.Any, opts ...FlagOption) {
-	cfg := defaultFlagConfig(opts...)
-	if !cfg.autoUnMarshalFlag {
-		return
-	}
+/////////////////////////////////////////////////////// group ///////////////////////////////////////////////////////
 
-	if cmd.PreRun != nil {
-		handler := cmd.PreRun
-		cmd.PreRun = func(cmd *cobra.Command, args []string) {
-			if cfg.preAutoUnMarshal != nil {
-				cfg.preAutoUnMarshal(cmd, args)
-			}
-			_ = UnmarshalFlags(v0, opts...)
+// flagGroups flag name groups for the cobra flag group constraints
+type flagGroups [][]string
 
-			handler(cmd, args)
+// add the names are deduplicated, and the group is ignored if the same set already exists
+func (g flagGroups) add(names ...string) flagGroups {
+	group := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			group = append(group, name)
 		}
-	} else if cmd.PreRunE != nil {
-		handler := cmd.PreRunE
-		cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
-			if cfg.preAutoUnMarshalE != nil {
-				if err := cfg.preAutoUnMarshalE(cmd, args); err != nil {
-					return err
-				}
-			}
-			if err := UnmarshalFlags(v0, opts...); err != nil {
-				return err
-			}
+	}
 
-			return handler(cmd, args)
+	for _, exist := range g {
+		if sameFlagGroup(exist, group) {
+			return g
 		}
 	}
+	return append(g, group)
 }
 
-func defaultFlagConfig(opts ...FlagOption) *FlagConfig {
-	cfg := &FlagConfig{
-		tagName:     TagName,
-		tagLabelSep: TagLabelSep,
-		squash:      true,
-	}
-	for _, opt := range opts {
-		opt(cfg)
+func sameFlagGroup(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
 	}
-	return cfg
-}
 
-func isStepInto(field reflect.StructField) bool {
-	return field.Type.Kind() == reflect.Struct ||
-		(field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct)
+	x, y := append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
 }
 
-func tryStepOut(field reflect.StructField, cfg *FlagConfig) {
-	if len(cfg.parent) == 0 {
-		return
+// checkFlagDefined pflag panics on the redefined flag names and shorthands
+// target is the flag set to register, e.g. the flag set of `WithFlagSetOption`
+func checkFlagDefined(cmd *cobra.Command, target *flag.FlagSet, field reflect.StructField, tag *tagData) error {
+	flagSets := []*flag.FlagSet{cmd.Flags(), cmd.PersistentFlags(), target}
+	for _, flagSet := range flagSets {
+		if flagSet.Lookup(tag.Name) != nil {
+			return fmt.Errorf("flag %q already defined (conflicts with field %s)", tag.Name, field.Name)
+		}
 	}
 
-	tag := getTag(field, cfg)
-	squash := cfg.squash || tag != nil && tag.squash
-	if squash {
-		return
+	switch {
+	case len(tag.Short) == 0:
+		return nil
+	case len(tag.Short) > 1:
+		return fmt.Errorf("flag %q shorthand %q is more than one ASCII character (field %s)", tag.Name, tag.Short, field.Name)
 	}
+	for _, flagSet := range flagSets {
+		if f := flagSet.ShorthandLookup(tag.Short); f != nil {
+			return fmt.Errorf("flag shorthand %q already defined by %q (conflicts with field %s)", tag.Short, f.Name, field.Name)
+		}
+	}
+	return nil
+}
 
-	cfg.parent = cfg.parent[:len(cfg.parent)-1]
+// markFlagGroups cobra panics on unknown flags, so check them first
+func markFlagGroups(cmd *cobra.Command, cfg *FlagConfig) error {
+	for _, group := range cfg.requiredTogether {
+		if err := lookupFlags(cmd, group); err != nil {
+			return err
+		}
+		cmd.MarkFlagsRequiredTogether(group...)
+	}
+	for _, group := range cfg.mutuallyExclusive {
+		if err := lookupFlags(cmd, group); err != nil {
+			return err
+		}
+		cmd.MarkFlagsMutuallyExclusive(group...)
+	}
+	for _, group := range cfg.oneRequired {
+		if err := lookupFlags(cmd, group); err != nil {
+			return err
+		}
+		cmd.MarkFlagsOneRequired(group...)
+	}
+	return nil
 }
 
-func getFlagSet(cmd *cobra.Command, cfg *FlagConfig) *flag.FlagSet {
-	switch cfg.persist {
-	case true:
-		return cmd.PersistentFlags()
-	default:
-		return cmd.Flags()
+func lookupFlags(cmd *cobra.Command, names []string) error {
+	for _, name := range names {
+		if cmd.Flags().Lookup(name) == nil && cmd.PersistentFlags().Lookup(name) == nil {
+			return fmt.Errorf("flag %q not found in group %v", name, names)
+		}
 	}
+	return nil
 }
 
 // ///////////////////////////////////////////////////// tag ///////////////////////////////////////////////////////
@@ -424,44 +1926,113 @@ func getFlagSet(cmd *cobra.Command, cfg *FlagConfig) *flag.FlagSet {
 // private
 type tagData struct {
 	origin  string
+	squash  bool
 	Name    string
 	Short   string
 	Desc    string
 	Default string
-	squash  bool
+	Format  string
+	// cobra.MarkFlagRequired
+	Required bool
+	// flagSet.MarkHidden
+	Hidden bool
+	// flagSet.MarkDeprecated, the deprecation message
+	Deprecated string
+	// viper.BindEnv, the environment variable name
+	Env string
+	// register in `cmd.PersistentFlags()`
+	Persistent bool
+	// flagSet.CountVarP
+	Count bool
+	// flagSet.StringArrayVarP
+	Array bool
+	// separator of the slice default value, default is ","
+	Sep string
+	// the key used to read the value from viper, default is the flag name
+	ViperKey string
+	// the allowed values
+	OneOf []string
+	// the numeric range, both bounds are optional
+	Min string
+	Max string
+	// RE2 regular expression the string value must match
+	Pattern string
+	// flags that must be set together with this flag
+	RequiredWith []string
+	// flags that cannot be set together with this flag
+	ExclusiveWith []string
+	// cobra.MarkFlagFilename, with the optional extensions
+	Filename bool
+	FileExts []string
+	// cobra.MarkFlagDirname
+	Dirname bool
+	// the layout of time.Time, default is time.RFC3339
+	Layout string
+	// flagSet.SetAnnotation, key -> values
+	Annotations map[string][]string
+}
+
+// key the key used to read the value from viper
+func (tag *tagData) key() string {
+	if len(tag.ViperKey) > 0 {
+		return tag.ViperKey
+	}
+	return tag.Name
 }
 
-func parseTag(field reflect.StructField, cfg *FlagConfig) *tagData {
-	if !field.IsExported() {
+func parseTag(field reflect.StructField, settings fieldTag, cfg *FlagConfig) *tagData {
+	// the unexported embedded struct is skipped with its fields, e.g. type Top struct { base }
+	// the nil pointer of it cannot be allocated by reflect
+	if field.Anonymous && !field.IsExported() {
 		return nil
 	}
-	tag := getTag(field, cfg)
-	if tag == nil {
+	if !field.IsExported() || isIgnoredType(field.Type, cfg) {
 		return nil
 	}
-
-	// add prefix
-	// type Base struct {Name string}
-	// type Top struct {Base; Level int}
-	// skip `Base` field // ignoreUntaggedFields == true
-	// --name // ignoreUntaggedFields == false && (cfg.Squash == true || ".squash" in tag)
-	// --base.name // ignoreUntaggedFields == false && squash == false
-	if !cfg.squash && !tag.squash && isStepInto(field) {
-		cfg.parent = append(cfg.parent, tag.origin)
+	if cfg.fieldFilter != nil && !cfg.fieldFilter(field) {
+		return nil
 	}
+	return getTag(field, settings, cfg)
+}
 
-	return tag
+// tagCacheKey the labels only depend on the struct type, the tag name and the label separator
+type tagCacheKey struct {
+	typ         reflect.Type
+	tagName     string
+	tagLabelSep string
 }
 
-// getTag .
-func getTag(field reflect.StructField, cfg *FlagConfig) *tagData {
-	fulls, ok := field.Tag.Lookup(cfg.tagName)
+// fieldTag the labels of the field tag, ok is false if the field is untagged, read-only once cached
+type fieldTag struct {
+	settings map[string]string
+	ok       bool
+}
 
-	// ignore untagged field
-	if cfg.ignoreUntaggedFields && !ok {
-		return nil
+// tagCache tagCacheKey -> []fieldTag by the field index, never invalidated since the struct types are immutable
+var tagCache sync.Map
+
+// getFieldTags the labels of all fields of the struct type t, parsed once per type
+func getFieldTags(t reflect.Type, cfg *FlagConfig) []fieldTag {
+	key := tagCacheKey{typ: t, tagName: cfg.tagName, tagLabelSep: cfg.tagLabelSep}
+	if !cfg.noCache {
+		if tags, ok := tagCache.Load(key); ok {
+			return tags.([]fieldTag)
+		}
+	}
+
+	tags := make([]fieldTag, t.NumField())
+	for i := range tags {
+		tags[i] = parseTagSettings(t.Field(i), cfg)
+	}
+	if !cfg.noCache {
+		tagCache.Store(key, tags)
 	}
+	return tags
+}
 
+// parseTagSettings the labels of the field tag
+func parseTagSettings(field reflect.StructField, cfg *FlagConfig) fieldTag {
+	fulls, ok := field.Tag.Lookup(cfg.tagName)
 	names := strings.Split(strings.TrimSpace(fulls), cfg.tagLabelSep)
 	settings := make(map[string]string)
 	for i := 0; i < len(names); i++ {
@@ -488,6 +2059,17 @@ func getTag(field reflect.StructField, cfg *FlagConfig) *tagData {
 			settings[k] = k
 		}
 	}
+	return fieldTag{settings: settings, ok: ok}
+}
+
+// getTag .
+func getTag(field reflect.StructField, tags fieldTag, cfg *FlagConfig) *tagData {
+	settings, ok := tags.settings, tags.ok
+
+	// ignore untagged field
+	if cfg.ignoreUntaggedFields && !ok {
+		return nil
+	}
 
 	// skip `-`
 	if settings[cfg.tagName] == TagLabelSkip {
@@ -498,13 +2080,49 @@ func getTag(field reflect.StructField, cfg *FlagConfig) *tagData {
 		Short:   settings[TagLabelShort],
 		Desc:    settings[TagLabelDesc],
 		Default: settings[TagLabelDefault],
+		Format:  settings[TagLabelFormat],
 	}
 
 	// untagged field use field name as the flag name
 	if len(tag.Name) == 0 {
-		tag.Name = strings.ToLower(field.Name)
+		tag.Name = fieldFlagName(field, cfg)
 	}
 
+	_, tag.Required = settings[TagLabelRequired]
+	_, tag.Hidden = settings[TagLabelHidden]
+	tag.Deprecated = strings.TrimSpace(settings[TagLabelDeprecated])
+	tag.Env = strings.TrimSpace(settings[TagLabelEnv])
+	_, tag.Persistent = settings[TagLabelPersistent]
+	_, tag.Count = settings[TagLabelCount]
+	_, tag.Array = settings[TagLabelArray]
+	tag.ViperKey = strings.TrimSpace(settings[TagLabelViperKey])
+	tag.OneOf = stringx.SafeTokens(settings[TagLabelOneOf], "|")
+	tag.Min = strings.TrimSpace(settings[TagLabelMin])
+	tag.Max = strings.TrimSpace(settings[TagLabelMax])
+	tag.Pattern = settings[TagLabelPattern]
+	tag.RequiredWith = stringx.SafeTokens(settings[TagLabelRequiredWith], ":")
+	tag.ExclusiveWith = stringx.SafeTokens(settings[TagLabelExclusiveWith], ":")
+	_, tag.Dirname = settings[TagLabelDirname]
+	tag.Layout = settings[TagLabelLayout]
+	for _, annotation := range stringx.SafeTokens(settings[TagLabelAnnotation], ":") {
+		key, values, _ := strings.Cut(annotation, "=")
+		if tag.Annotations == nil {
+			tag.Annotations = make(map[string][]string)
+		}
+		tag.Annotations[strings.TrimSpace(key)] = stringx.SafeTokens(values, "|")
+	}
+	if exts, ok := settings[TagLabelFilename]; ok {
+		tag.Filename = true
+		// `filename` without extensions
+		if exts != TagLabelFilename {
+			for _, ext := range stringx.SafeTokens(exts, ":") {
+				tag.FileExts = append(tag.FileExts, strings.TrimPrefix(ext, "."))
+			}
+		}
+	}
+	if tag.Sep = settings[TagLabelSliceSep]; len(tag.Sep) == 0 {
+		tag.Sep = ","
+	}
 	_, squashLabel := settings[TagLabelSquash]
 	tag.squash = squashLabel && isStepInto(field)
 	tag.origin = tag.Name
@@ -515,9 +2133,25 @@ func getTag(field reflect.StructField, cfg *FlagConfig) *tagData {
 	// skip `Base` field // ignoreUntaggedFields == true
 	// --name // ignoreUntaggedFields == false && (cfg.Squash == true || ".squash" in tag)
 	// --base.name // ignoreUntaggedFields == false && squash == false
-	if !cfg.squash {
-		if len(cfg.parent) > 0 {
-			tag.Name = strings.Join(cfg.parent, ".") + "." + tag.Name
+	if len(cfg.parent) > 0 {
+		tag.Name = strings.Join(cfg.parent, cfg.nestedSep) + cfg.nestedSep + tag.Name
+	}
+	// namespace of all flags, e.g. --server.port
+	if len(cfg.prefix) > 0 {
+		tag.Name = cfg.prefix + cfg.prefixSep + tag.Name
+	}
+	// struct names are only used as the prefix of the nested flags
+	if !isStepInto(field) && !isStructSlice(field.Type) {
+		for _, fn := range cfg.nameTransformers {
+			tag.Name = fn(tag.Name)
+		}
+		// the flag, the viper key and the key read by `ReadFlags` are the same normalized name
+		tag.Name = normalizeName(cfg.normalize, tag.Name)
+		// dynamic default overrides the `default` label
+		if cfg.defaultProvider != nil {
+			if value, found := cfg.defaultProvider(tag.Name); found {
+				tag.Default = value
+			}
 		}
 	}
 
@@ -526,103 +2160,430 @@ func getTag(field reflect.StructField, cfg *FlagConfig) *tagData {
 
 /////////////////////////////////////////////////////// struct ///////////////////////////////////////////////////////
 
-func bindStruct(cmd *cobra.Command, fValue reflect.Value, field reflect.StructField, cfg *FlagConfig) error {
-	defer tryStepOut(field, cfg)
+func bindStruct(cmd *cobra.Command, fValue reflect.Value, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	defer stepInto(field, tag, cfg)()
+	if err := checkCycle(field.Type, field, cfg); err != nil {
+		return err
+	}
 	return bindFlags(cmd, fValue.Addr().Interface(), cfg)
 }
 
-func readStruct(fValue reflect.Value, field reflect.StructField, cfg *FlagConfig) error {
-	defer tryStepOut(field, cfg)
+func readStruct(fValue reflect.Value, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	defer stepInto(field, tag, cfg)()
+	if err := checkCycle(field.Type, field, cfg); err != nil {
+		return err
+	}
 	return readFlags(fValue.Addr().Interface(), cfg)
 }
 
+// bindStructSlice bind the fields of each element with the index prefix, e.g. `--server.0.host`
+// only the elements already in the slice are bound
+func bindStructSlice(cmd *cobra.Command, fValue reflect.Value, tag *tagData, cfg *FlagConfig) error {
+	parent := cfg.parent
+	defer func() { cfg.parent = parent }()
+	// the field name is the last element, e.g. Items -> Items[0]
+	last := len(cfg.fieldPath) - 1
+	name := cfg.fieldPath[last]
+	defer func() { cfg.fieldPath[last] = name }()
+
+	for i := 0; i < fValue.Len(); i++ {
+		cfg.parent = append(parent[:len(parent):len(parent)], tag.origin, strconv.Itoa(i))
+		cfg.fieldPath[last] = fmt.Sprintf("%s[%d]", name, i)
+		if err := bindFlags(cmd, fValue.Index(i).Addr().Interface(), cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readStructSlice(fValue reflect.Value, tag *tagData, cfg *FlagConfig) error {
+	parent := cfg.parent
+	defer func() { cfg.parent = parent }()
+
+	for i := 0; i < fValue.Len(); i++ {
+		cfg.parent = append(parent[:len(parent):len(parent)], tag.origin, strconv.Itoa(i))
+		if err := readFlags(fValue.Index(i).Addr().Interface(), cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 /////////////////////////////////////////////////////// pointer ///////////////////////////////////////////////////////
 
-func bindPointer(cmd *cobra.Command, fValue reflect.Value, field reflect.StructField, cfg *FlagConfig) error {
-	if fValue.IsNil() {
-		return fmt.Errorf("nil value of *%s", field.Name)
+// isPrimitivePointer *string, *bool, *int, *int32, *int64, *float32, *float64 and the pointer to duration,
+// the nil pointer is allocated only when the value is set, see `pointerValue`
+func isPrimitivePointer(t reflect.Type) bool {
+	if t.Kind() != reflect.Pointer {
+		return false
+	}
+	if isDurationType(t.Elem()) {
+		return true
 	}
 
-	if fValue.Elem().Kind() != reflect.Struct {
-		return fmt.Errorf("unsupported type: %s|%s(%s)", field.Name, fValue.Kind(), fValue.Elem().Kind())
+	switch t.Elem() {
+	case reflect.TypeOf(""), reflect.TypeOf(false), reflect.TypeOf(0), reflect.TypeOf(int32(0)), reflect.TypeOf(int64(0)),
+		reflect.TypeOf(float32(0)), reflect.TypeOf(float64(0)):
+		return true
+	default:
+		return false
 	}
-	defer tryStepOut(field, cfg)
-	return bindFlags(cmd, fValue.Interface(), cfg)
 }
 
-func readPointer(fValue reflect.Value, field reflect.StructField, cfg *FlagConfig) error {
-	if fValue.IsNil() {
-		return fmt.Errorf("nil value of *%s", field.Name)
+// pointerValue the nil pointer to primitive, it is allocated only when the flag is set,
+// so that nil means the flag is not given, e.g. *string is nil without `--name` and "" with `--name ""`
+type pointerValue struct {
+	pointer reflect.Value
+	tag     *tagData
+}
+
+var _ flag.Value = (*pointerValue)(nil)
+
+func (v *pointerValue) Set(s string) error {
+	value := reflect.New(v.pointer.Type().Elem()).Elem()
+	if err := parseValue(value, s, v.tag); err != nil {
+		return err
+	}
+	allocElem(v.pointer).Set(value)
+	return nil
+}
+
+// String the nil pointer is empty, so that no default is shown
+func (v *pointerValue) String() string {
+	if v.pointer.IsNil() {
+		return ""
+	}
+	if elem := v.pointer.Elem(); isDurationType(elem.Type()) {
+		return time.Duration(elem.Int()).String()
+	}
+	return fmt.Sprint(v.pointer.Elem().Interface())
+}
+
+// Type the pflag type of the pointed-to value, e.g. string, int64, duration
+func (v *pointerValue) Type() string {
+	if isDurationType(v.pointer.Type().Elem()) {
+		return "duration"
+	}
+	return v.pointer.Type().Elem().Kind().String()
+}
+
+// bindPointerValue the pointer is allocated with the `default` label, otherwise it is left nil until the flag is set
+func bindPointerValue(flagSet *flag.FlagSet, fValue reflect.Value, field reflect.StructField, tag *tagData) error {
+	value := &pointerValue{pointer: fValue, tag: tag}
+	if len(tag.Default) > 0 {
+		if _, err := parseDefault(fValue.Type().Elem(), tag); err != nil {
+			return err
+		}
+		_ = value.Set(tag.Default)
+	}
+	f := flagSet.VarPF(value, tag.Name, tag.Short, tag.Desc)
+	// `--debug` without value, like `flagSet.BoolVarP`
+	if fValue.Type().Elem().Kind() == reflect.Bool {
+		f.NoOptDefVal = "true"
+	}
+	return nil
+}
+
+func bindPointer(cmd *cobra.Command, fValue reflect.Value, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	defer stepInto(field, tag, cfg)()
+	switch {
+	case isPrimitivePointer(fValue.Type()) && fValue.IsNil():
+		return bindField(cmd, fValue, field, tag, cfg)
+	case isPrimitivePointer(fValue.Type()):
+		return bindField(cmd, fValue.Elem(), field, tag, cfg)
+	case fValue.Type().Elem().Kind() != reflect.Struct:
+		return fmt.Errorf("unsupported type: %s", fValue.Type())
+	}
+
+	if err := checkCycle(fValue.Type().Elem(), field, cfg); err != nil {
+		return err
+	}
+	if err := allocPointer(fValue, field, cfg); err != nil {
+		return err
 	}
+	return bindFlags(cmd, fValue.Interface(), cfg)
+}
 
-	if fValue.Elem().Kind() != reflect.Struct {
-		return fmt.Errorf("unsupported type: %s|%s(%s)", field.Name, fValue.Kind(), fValue.Elem().Kind())
+func readPointer(fValue reflect.Value, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	defer stepInto(field, tag, cfg)()
+	switch {
+	case isPrimitivePointer(fValue.Type()):
+		// the nil pointer is left nil if neither the value nor the default is set
+		if fValue.IsNil() && len(tag.Default) == 0 && !getViper(cfg).IsSet(tag.key()) {
+			return nil
+		}
+		return readValue(allocElem(fValue), field, tag, cfg)
+	case fValue.Type().Elem().Kind() != reflect.Struct:
+		return fmt.Errorf("unsupported type: %s|%s(%s)", field.Name, fValue.Kind(), fValue.Type().Elem().Kind())
 	}
 
-	defer tryStepOut(field, cfg)
+	if err := checkCycle(fValue.Type().Elem(), field, cfg); err != nil {
+		return err
+	}
+	if err := allocPointer(fValue, field, cfg); err != nil {
+		return err
+	}
 	return readFlags(fValue.Interface(), cfg)
 }
 
+// visitType mark the struct type as being traversed, the returned function unmarks it
+func visitType(t reflect.Type, cfg *FlagConfig) func() {
+	if cfg.visitedTypes[t] {
+		return func() {}
+	}
+	cfg.visitedTypes[t] = true
+	return func() { delete(cfg.visitedTypes, t) }
+}
+
+// checkCycle the nested struct type is one of the struct types being traversed, e.g. `type Node struct { Next *Node }`
+func checkCycle(t reflect.Type, field reflect.StructField, cfg *FlagConfig) error {
+	if cfg.visitedTypes[t] {
+		return fmt.Errorf("cycle detected in struct field %s", field.Name)
+	}
+	return nil
+}
+
+// allocPointer allocate the zero value for nil pointers, unless `WithStrictNilPointersOption` is set
+func allocPointer(fValue reflect.Value, field reflect.StructField, cfg *FlagConfig) error {
+	if !fValue.IsNil() {
+		return nil
+	}
+
+	if cfg.strictNilPointers {
+		return fmt.Errorf("nil value of *%s", field.Name)
+	}
+	fValue.Set(reflect.New(field.Type.Elem()))
+	return nil
+}
+
+/////////////////////////////////////////////////////// int ///////////////////////////////////////////////////////
+
+// bindInt `count` label increments the value each time the flag appears, e.g. `-vvv`
+func bindInt(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) {
+	switch tag.Count {
+	case true:
+		flagSet.CountVarP(fValue.Addr().Interface().(*int), tag.Name, tag.Short, tag.Desc)
+	default:
+		flagSet.IntVarP(fValue.Addr().Interface().(*int), tag.Name, tag.Short, stringx.Atoi[int](tag.Default), tag.Desc)
+	}
+}
+
 /////////////////////////////////////////////////////// int64 ///////////////////////////////////////////////////////
 
+// bindInt64 time.Duration and the types of `RegisterDurationType` are bound as duration, the other named int64 types as int64
 func bindInt64(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) {
-	switch fValue.Addr().Interface().(type) {
-	case *time.Duration:
-		duration, _ := time.ParseDuration(tag.Default)
-		flagSet.DurationVarP(fValue.Addr().Interface().(*time.Duration), tag.Name, tag.Short, duration, tag.Desc)
+	switch {
+	case isDurationType(fValue.Type()):
+		bindDuration(flagSet, fValue, tag)
 	default:
-		flagSet.Int64VarP(fValue.Addr().Interface().(*int64), tag.Name, tag.Short, stringx.Atoi[int64](tag.Default), tag.Desc)
+		p := fValue.Addr().Convert(reflect.TypeOf((*int64)(nil))).Interface().(*int64)
+		flagSet.Int64VarP(p, tag.Name, tag.Short, stringx.Atoi[int64](tag.Default), tag.Desc)
 	}
 }
 
-func readInt64(fValue reflect.Value, tag *tagData) {
-	i := fValue.Addr().Interface()
-	switch i.(type) {
-	case *time.Duration:
-		fValue.Set(reflect.ValueOf(viper.GetDuration(tag.Name)))
+func readInt64(vp *viper.Viper, fValue reflect.Value, tag *tagData) builtin.Any {
+	switch {
+	case isDurationType(fValue.Type()):
+		return vp.GetDuration(tag.key())
 	default:
-		fValue.Set(reflect.ValueOf(viper.GetInt64(tag.Name)))
+		return vp.GetInt64(tag.key())
 	}
 }
 
 /////////////////////////////////////////////////////// slice ///////////////////////////////////////////////////////
 
 func bindSlice(flagSet *flag.FlagSet, fValue reflect.Value, field reflect.StructField, tag *tagData) error {
+	if fValue.Type() == reflect.TypeOf([]byte{}) {
+		bindBytes(flagSet, fValue, tag)
+		return nil
+	}
+
+	switch fValue.Type().Elem() {
+	case reflect.TypeOf(time.Duration(0)):
+		bindDurationSlice(flagSet, fValue, tag)
+		return nil
+	case reflect.TypeOf(net.IP{}):
+		bindIPSlice(flagSet, fValue, tag)
+		return nil
+	}
+
 	switch fValue.Type().Elem().Kind() {
 	case reflect.String:
 		bindStringSlice(flagSet, fValue, tag)
 	case reflect.Int:
 		bindIntSlice(flagSet, fValue, tag)
 	default:
-		return fmt.Errorf("field `%s` unsupported slice type %s", field.Name, fValue.Type().Elem().Kind())
+		return fmt.Errorf("unsupported slice type: %s", fValue.Type())
 	}
 	return nil
 }
 
-func readSlice(fValue reflect.Value, tag *tagData) error {
+func readSlice(vp *viper.Viper, fValue reflect.Value, tag *tagData) (builtin.Any, error) {
+	if fValue.Type() == reflect.TypeOf([]byte{}) {
+		return readBytes(vp, tag), nil
+	}
+
+	switch fValue.Type().Elem() {
+	case reflect.TypeOf(time.Duration(0)):
+		return readDurationSlice(vp, tag), nil
+	case reflect.TypeOf(net.IP{}):
+		return readIPSlice(vp, tag), nil
+	}
+
 	switch fValue.Type().Elem().Kind() {
 	case reflect.String:
-		readStringSlice(fValue, tag)
+		return readStringSlice(vp, tag), nil
 	case reflect.Int:
-		readIntSlice(fValue, tag)
+		return readIntSlice(vp, tag), nil
 	default:
-		return fmt.Errorf("unsupported slice type: %s|%s", fValue.Type().Elem().Name(), fValue.Type().Elem().Kind())
+		return nil, fmt.Errorf("unsupported slice type: %s|%s", fValue.Type().Elem().Name(), fValue.Type().Elem().Kind())
 	}
-	return nil
 }
 
 func bindIntSlice(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) {
-	flagSet.IntSliceVarP(fValue.Addr().Interface().(*[]int), tag.Name, tag.Short, stringx.AtoSlice[int](tag.Default, ","), tag.Desc)
+	flagSet.IntSliceVarP(fValue.Addr().Interface().(*[]int), tag.Name, tag.Short, stringx.AtoSlice[int](tag.Default, tag.Sep), tag.Desc)
 }
 
-func readIntSlice(fValue reflect.Value, tag *tagData) {
-	fValue.Set(reflect.ValueOf(viper.GetIntSlice(tag.Name)))
+func readIntSlice(vp *viper.Viper, tag *tagData) []int {
+	return vp.GetIntSlice(tag.key())
 }
 
+// bindStringSlice `array` label keeps each occurrence as a single value, `--label key=a,b` -> ["key=a,b"]
 func bindStringSlice(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) {
-	flagSet.StringSliceVarP(fValue.Addr().Interface().(*[]string), tag.Name, tag.Short, stringx.Split(tag.Default, ","), tag.Desc)
+	switch tag.Array {
+	case true:
+		flagSet.StringArrayVarP(fValue.Addr().Interface().(*[]string), tag.Name, tag.Short, stringx.Split(tag.Default, tag.Sep), tag.Desc)
+	default:
+		flagSet.StringSliceVarP(fValue.Addr().Interface().(*[]string), tag.Name, tag.Short, stringx.Split(tag.Default, tag.Sep), tag.Desc)
+	}
+}
+
+func readStringSlice(vp *viper.Viper, tag *tagData) []string {
+	return vp.GetStringSlice(tag.key())
+}
+
+func bindDurationSlice(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) {
+	flagSet.DurationSliceVarP(fValue.Addr().Interface().(*[]time.Duration), tag.Name, tag.Short, stringx.ToDurationSlice(tag.Default, tag.Sep), tag.Desc)
+}
+
+// viper has no `GetDurationSlice`, pflag values come back as []time.Duration, others as "1s,5s" or a list
+func readDurationSlice(vp *viper.Viper, tag *tagData) []time.Duration {
+	value := vp.Get(tag.key())
+	if s, ok := value.(string); ok {
+		return stringx.ToDurationSlice(strings.Trim(s, "[]"), ",")
+	}
+	return cast.ToDurationSlice(value)
+}
+
+func bindIPSlice(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) {
+	flagSet.IPSliceVarP(fValue.Addr().Interface().(*[]net.IP), tag.Name, tag.Short, parseIPs(stringx.SafeTokens(tag.Default, tag.Sep)), tag.Desc)
+}
+
+func readIPSlice(vp *viper.Viper, tag *tagData) []net.IP {
+	return parseIPs(readCSV(vp, tag.key()))
+}
+
+// parseIPs invalid addresses are skipped
+func parseIPs(ss []string) []net.IP {
+	if len(ss) == 0 {
+		return nil
+	}
+
+	l := make([]net.IP, 0, len(ss))
+	for _, s := range ss {
+		if ip := net.ParseIP(s); ip != nil {
+			l = append(l, ip)
+		}
+	}
+	return l
+}
+
+// readCSV viper returns the value of pflag types it doesn't know as "[a,b]"
+func readCSV(vp *viper.Viper, name string) []string {
+	if s, ok := vp.Get(name).(string); ok {
+		return stringx.SafeTokens(strings.Trim(s, "[]"), ",")
+	}
+	return vp.GetStringSlice(name)
+}
+
+/////////////////////////////////////////////////////// bytes ///////////////////////////////////////////////////////
+
+// []byte is base64 encoded by default, `format:hex` for hex encoded
+func bindBytes(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) {
+	p := fValue.Addr().Interface().(*[]byte)
+	switch tag.Format {
+	case FormatHex:
+		flagSet.BytesHexVarP(p, tag.Name, tag.Short, decodeBytes(tag.Default, tag.Format), tag.Desc)
+	default:
+		flagSet.BytesBase64VarP(p, tag.Name, tag.Short, decodeBytes(tag.Default, tag.Format), tag.Desc)
+	}
+}
+
+func readBytes(vp *viper.Viper, tag *tagData) []byte {
+	return decodeBytes(vp.GetString(tag.key()), tag.Format)
+}
+
+// decodeBytes invalid strings are decoded as nil
+func decodeBytes(s string, format string) []byte {
+	var (
+		b   []byte
+		err error
+	)
+	switch format {
+	case FormatHex:
+		b, err = hex.DecodeString(strings.TrimSpace(s))
+	default:
+		b, err = base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	}
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+/////////////////////////////////////////////////////// map ///////////////////////////////////////////////////////
+
+// bindMap map[string]bool, e.g. `--features cache,debug=false`
+// map[string]interface{}, e.g. `--extra '{"key":"value","num":42}'`
+func bindMap(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) error {
+	// the default is checked by `parseDefault`
+	switch fValue.Type() {
+	case reflect.TypeOf(map[string]bool{}):
+		p := fValue.Addr().Interface().(*map[string]bool)
+		*p, _ = parseBoolMap(tag.Default, tag.Sep)
+		flagSet.VarP(newBoolMapValue(p, tag.Sep), tag.Name, tag.Short, tag.Desc)
+	case jsonMapType:
+		p := fValue.Addr().Interface().(*map[string]builtin.Any)
+		*p, _ = parseJSONMap(tag.Default)
+		flagSet.VarP(newJSONMapValue(p), tag.Name, tag.Short, tag.Desc)
+	default:
+		return fmt.Errorf("unsupported map type: %s", fValue.Type())
+	}
+	return nil
 }
 
-func readStringSlice(fValue reflect.Value, tag *tagData) {
-	fValue.Set(reflect.ValueOf(viper.GetStringSlice(tag.Name)))
+// readMap the flag and env are strings, the config file is the map
+func readMap(vp *viper.Viper, fValue reflect.Value, tag *tagData) (builtin.Any, error) {
+	switch fValue.Type() {
+	case reflect.TypeOf(map[string]bool{}):
+		switch value := vp.Get(tag.key()).(type) {
+		case nil:
+			return map[string]bool(nil), nil
+		case string:
+			return parseBoolMap(value, tag.Sep)
+		default:
+			return cast.ToStringMapBoolE(value)
+		}
+	case jsonMapType:
+		switch value := vp.Get(tag.key()).(type) {
+		case nil:
+			return map[string]builtin.Any(nil), nil
+		case string:
+			return parseJSONMap(value)
+		default:
+			return cast.ToStringMapE(value)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported map type: %s", fValue.Type())
+	}
 }
@@ -0,0 +1,736 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// newTestCommand the command with an isolated viper
+func newTestCommand() (*cobra.Command, *viper.Viper) {
+	return &cobra.Command{Use: "app", Run: func(*cobra.Command, []string) {}}, viper.New()
+}
+
+func TestBindStructSlice(t *testing.T) {
+	type ServerConfig struct {
+		Host string `flag:"host"`
+		Port int    `flag:"port,default:80"`
+	}
+	type Config struct {
+		Server []ServerConfig `flag:"server"`
+	}
+
+	c := Config{Server: make([]ServerConfig, 2)}
+	cmd, vp := newTestCommand()
+	if err := BindFlags(cmd, &c, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"server.0.host", "server.0.port", "server.1.host", "server.1.port"} {
+		if cmd.Flags().Lookup(name) == nil {
+			t.Errorf("flag %s not registered", name)
+		}
+	}
+
+	cmd.SetArgs([]string{"--server.0.host", "a.example.com", "--server.1.host", "b.example.com", "--server.1.port", "8080"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	want := []ServerConfig{{Host: "a.example.com", Port: 80}, {Host: "b.example.com", Port: 8080}}
+	for i := range want {
+		if c.Server[i] != want[i] {
+			t.Errorf("Server[%d] = %+v, want %+v", i, c.Server[i], want[i])
+		}
+	}
+
+	var r Config
+	r.Server = make([]ServerConfig, 2)
+	if err := ReadFlags(&r, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if r.Server[i] != want[i] {
+			t.Errorf("ReadFlags Server[%d] = %+v, want %+v", i, r.Server[i], want[i])
+		}
+	}
+}
+
+func TestPrefixUnmarshal(t *testing.T) {
+	type Database struct {
+		Host string `flag:"host,default:localhost"`
+	}
+	type Config struct {
+		Port int      `flag:"port,default:1"`
+		DB   Database `flag:"db"`
+	}
+
+	tests := []struct {
+		name string
+		sep  string
+	}{
+		{name: "nested keys", sep: "."},
+		{name: "flat keys", sep: "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []FlagOption{WithPrefixOption("server"), WithPrefixSepOption(tt.sep), WithSquashOption(false)}
+			var c Config
+			cmd, vp := newTestCommand()
+			opts = append(opts, WithViperOption(vp))
+			if err := BindFlags(cmd, &c, append(opts, WithAutoUnMarshalOption())...); err != nil {
+				t.Fatal(err)
+			}
+			vp.Set("server"+tt.sep+"port", 77)
+			vp.Set("other", "ignored")
+			cmd.SetArgs([]string{"--server" + tt.sep + "db.host", "db.example.com"})
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+
+			want := Config{Port: 77, DB: Database{Host: "db.example.com"}}
+			if c != want {
+				t.Errorf("auto unmarshal = %+v, want %+v", c, want)
+			}
+
+			var r Config
+			if err := ReadFlags(&r, opts...); err != nil {
+				t.Fatal(err)
+			}
+			var u Config
+			if err := UnmarshalFlags(&u, append(opts, WithStrictModeOption(true))...); err != nil {
+				t.Fatal(err)
+			}
+			if r != want || u != want {
+				t.Errorf("ReadFlags = %+v, UnmarshalFlags = %+v, want %+v", r, u, want)
+			}
+		})
+	}
+}
+
+func TestCamelCaseUnmarshal(t *testing.T) {
+	type Config struct {
+		DatabaseURL string
+		HTTPSPort   int    `flag:",default:443"`
+		LogLevel    string `flag:"log-level"`
+	}
+
+	tests := []struct {
+		name string
+		opt  FlagOption
+		// the config file key of HTTPSPort, which is not set on the command line
+		portKey string
+		args    []string
+	}{
+		{
+			name:    "kebab",
+			opt:     WithCamelToKebabOption(),
+			portKey: "https-port",
+			args:    []string{"--database-url", "postgres://db", "--log-level", "debug"},
+		},
+		{
+			name:    "snake",
+			opt:     WithCamelToSnakeOption(),
+			portKey: "https_port",
+			args:    []string{"--database_url", "postgres://db", "--log-level", "debug"},
+		},
+	}
+	want := Config{DatabaseURL: "postgres://db", HTTPSPort: 8443, LogLevel: "debug"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Config
+			cmd, vp := newTestCommand()
+			if err := BindFlags(cmd, &c, tt.opt, WithViperOption(vp), WithAutoUnMarshalOption()); err != nil {
+				t.Fatal(err)
+			}
+			vp.Set(tt.portKey, 8443)
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if c != want {
+				t.Errorf("auto unmarshal = %+v, want %+v", c, want)
+			}
+
+			var r, u Config
+			if err := ReadFlags(&r, tt.opt, WithViperOption(vp)); err != nil {
+				t.Fatal(err)
+			}
+			if err := UnmarshalFlags(&u, tt.opt, WithViperOption(vp)); err != nil {
+				t.Fatal(err)
+			}
+			if r != want || u != want {
+				t.Errorf("ReadFlags = %+v, UnmarshalFlags = %+v, want %+v", r, u, want)
+			}
+		})
+	}
+}
+
+func TestTagCachePerType(t *testing.T) {
+	type Cached struct {
+		Host string `flag:"host,default:localhost"`
+		Port int    `flag:"port,short:p"`
+		skip int
+	}
+	type Uncached struct {
+		Host string `flag:"host"`
+	}
+
+	if _, err := ListFlags(&Cached{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListFlags(&Uncached{}, WithNoCacheOption()); err != nil {
+		t.Fatal(err)
+	}
+
+	key := tagCacheKey{typ: reflect.TypeOf(Cached{}), tagName: TagName, tagLabelSep: TagLabelSep}
+	tags, ok := tagCache.Load(key)
+	if !ok {
+		t.Fatal("the tags of Cached are not cached")
+	}
+	if n := len(tags.([]fieldTag)); n != 3 {
+		t.Errorf("cached %d fields, want 3", n)
+	}
+	if got := tags.([]fieldTag)[1].settings[TagLabelShort]; got != "p" {
+		t.Errorf("short of port = %q, want p", got)
+	}
+	// the other tag names of the same type are cached separately
+	if _, ok := tagCache.Load(tagCacheKey{typ: key.typ, tagName: "mapstructure", tagLabelSep: TagLabelSep}); ok {
+		t.Error("the tags of mapstructure must not be cached")
+	}
+
+	if _, ok := tagCache.Load(tagCacheKey{typ: reflect.TypeOf(Uncached{}), tagName: TagName, tagLabelSep: TagLabelSep}); ok {
+		t.Error("WithNoCacheOption must not cache")
+	}
+}
+
+func TestNamedInt64(t *testing.T) {
+	type Timeout time.Duration
+	type UserID int64
+	type Config struct {
+		Wait    time.Duration `flag:"wait,default:1s"`
+		Timeout Timeout       `flag:"timeout,default:5s"`
+		User    UserID        `flag:"user,default:42"`
+	}
+	RegisterDurationType[Timeout]()
+
+	var c Config
+	cmd, vp := newTestCommand()
+	if err := BindFlags(cmd, &c, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	want := Config{Wait: time.Second, Timeout: Timeout(5 * time.Second), User: 42}
+	if c != want {
+		t.Errorf("defaults = %+v, want %+v", c, want)
+	}
+	for name, typ := range map[string]string{"wait": "duration", "timeout": "duration", "user": "int64"} {
+		if got := cmd.Flags().Lookup(name).Value.Type(); got != typ {
+			t.Errorf("type of %s = %q, want %q", name, got, typ)
+		}
+	}
+	if got := cmd.Flags().Lookup("user").DefValue; got != "42" {
+		t.Errorf("default of user = %q, want 42", got)
+	}
+	// the unregistered named int64 types do not accept the durations
+	if err := cmd.Flags().Set("user", "5m"); err == nil {
+		t.Error("--user 5m accepted, want error")
+	}
+
+	cmd.SetArgs([]string{"--wait", "2s", "--timeout", "1m", "--user", "43"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	want = Config{Wait: 2 * time.Second, Timeout: Timeout(time.Minute), User: 43}
+	if c != want {
+		t.Errorf("flags = %+v, want %+v", c, want)
+	}
+
+	var r, u Config
+	if err := ReadFlags(&r, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalFlags(&u, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	if r != want || u != want {
+		t.Errorf("ReadFlags = %+v, UnmarshalFlags = %+v, want %+v", r, u, want)
+	}
+
+	if err := ResetToDefaults(&r); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{Wait: time.Second, Timeout: Timeout(5 * time.Second), User: 42}); r != want {
+		t.Errorf("ResetToDefaults = %+v, want %+v", r, want)
+	}
+
+	var p struct {
+		Delay *Timeout `flag:"delay,default:3s"`
+	}
+	if err := BindFlags(&cobra.Command{Use: "app"}, &p, WithViperOption(viper.New())); err != nil {
+		t.Fatal(err)
+	}
+	if p.Delay == nil || *p.Delay != Timeout(3*time.Second) {
+		t.Errorf("Delay = %v, want 3s", p.Delay)
+	}
+}
+
+type embeddedBase struct {
+	Host string `flag:"host"`
+}
+
+type embeddedPointer struct {
+	Debug bool `flag:"debug"`
+}
+
+type EmbeddedBase struct {
+	Port int `flag:"port,default:1"`
+}
+
+func TestSkipUnexportedEmbedded(t *testing.T) {
+	type Config struct {
+		embeddedBase
+		*embeddedPointer
+		EmbeddedBase
+		Name string `flag:"name"`
+	}
+
+	var c Config
+	cmd, vp := newTestCommand()
+	if err := BindFlags(cmd, &c, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Flags().Lookup("host") != nil {
+		t.Error("the fields of the unexported embedded struct must be skipped")
+	}
+	if c.embeddedPointer != nil {
+		t.Error("the unexported embedded pointer must not be allocated")
+	}
+	// the exported embedded struct is squashed as before
+	for _, name := range []string{"port", "name"} {
+		if cmd.Flags().Lookup(name) == nil {
+			t.Errorf("flag %s not registered", name)
+		}
+	}
+
+	cmd.SetArgs([]string{"--port", "2"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var r Config
+	if err := ReadFlags(&r, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	if r.Port != 2 || r.Host != "" {
+		t.Errorf("ReadFlags = %+v, want Port 2 and empty Host", r)
+	}
+	infos, err := ListFlags(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Errorf("ListFlags = %d flags, want 2", len(infos))
+	}
+}
+
+func TestPersistentPreRunChain(t *testing.T) {
+	type Config struct {
+		Port int `flag:"port,default:1"`
+	}
+
+	tests := []struct {
+		name string
+		// existing hook of the command, either PersistentPreRun or PersistentPreRunE
+		errHook bool
+		errOpt  bool
+	}{
+		{name: "PersistentPreRun with E option"},
+		{name: "PersistentPreRunE with E option", errHook: true, errOpt: true},
+		{name: "PersistentPreRun with option"},
+		{name: "PersistentPreRunE with option", errHook: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				c     Config
+				calls []string
+			)
+			cmd, vp := newTestCommand()
+			if tt.errHook {
+				cmd.PersistentPreRunE = func(*cobra.Command, []string) error {
+					calls = append(calls, "existing")
+					return nil
+				}
+			} else {
+				cmd.PersistentPreRun = func(*cobra.Command, []string) { calls = append(calls, "existing") }
+			}
+
+			hook := func() { calls = append(calls, "option") }
+			opt := WithPersistentPreRunOption(func(*cobra.Command, []string) { hook() })
+			if tt.errOpt {
+				opt = WithPersistentPreRunEOption(func(*cobra.Command, []string) error {
+					hook()
+					return nil
+				})
+			}
+			if err := BindFlags(cmd, &c, WithViperOption(vp), opt); err != nil {
+				t.Fatal(err)
+			}
+			vp.Set("port", 2)
+			cmd.SetArgs(nil)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+
+			if want := []string{"existing", "option"}; !reflect.DeepEqual(calls, want) {
+				t.Errorf("calls = %v, want %v", calls, want)
+			}
+			if c.Port != 2 {
+				t.Errorf("Port = %d, want 2", c.Port)
+			}
+		})
+	}
+}
+
+func TestNoViperLabels(t *testing.T) {
+	type Config struct {
+		Host string `flag:"host,viperkey:server.host,env:APP_HOST"`
+		Port int    `flag:"port,default:1"`
+	}
+
+	viper.Reset()
+	defer viper.Reset()
+	var c Config
+	cmd := &cobra.Command{Use: "app", Run: func(*cobra.Command, []string) {}}
+	if err := BindFlags(cmd, &c, WithNoViperOption(), WithEnvPrefixOption("APP")); err != nil {
+		t.Fatal(err)
+	}
+	cmd.SetArgs([]string{"--host", "example.com"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{Host: "example.com", Port: 1}); c != want {
+		t.Errorf("flags = %+v, want %+v", c, want)
+	}
+	if keys := viper.AllKeys(); len(keys) != 0 {
+		t.Errorf("the global viper keys = %v, want none", keys)
+	}
+}
+
+func TestPrimitivePointerLazy(t *testing.T) {
+	type Config struct {
+		Name    *string        `flag:"name"`
+		Port    *int           `flag:"port"`
+		Debug   *bool          `flag:"debug"`
+		Timeout *time.Duration `flag:"timeout"`
+		Retries *int           `flag:"retries,default:3"`
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		// the fields expected to be non-nil
+		set map[string]bool
+	}{
+		{name: "omitted", set: map[string]bool{"Retries": true}},
+		{
+			name: "passed",
+			args: []string{"--name", "", "--port", "8080", "--debug", "--timeout", "5s"},
+			set:  map[string]bool{"Name": true, "Port": true, "Debug": true, "Timeout": true, "Retries": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Config
+			cmd, vp := newTestCommand()
+			if err := BindFlags(cmd, &c, WithViperOption(vp)); err != nil {
+				t.Fatal(err)
+			}
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+
+			var r Config
+			if err := ReadFlags(&r, WithViperOption(vp)); err != nil {
+				t.Fatal(err)
+			}
+			for _, got := range []Config{c, r} {
+				v := reflect.ValueOf(got)
+				for i := 0; i < v.NumField(); i++ {
+					name := v.Type().Field(i).Name
+					if isNil := v.Field(i).IsNil(); isNil == tt.set[name] {
+						t.Errorf("%s nil = %v, want %v", name, isNil, !tt.set[name])
+					}
+				}
+				if *got.Retries != 3 {
+					t.Errorf("Retries = %d, want 3", *got.Retries)
+				}
+			}
+			if tt.set["Port"] && (*c.Name != "" || *c.Port != 8080 || !*c.Debug || *c.Timeout != 5*time.Second) {
+				t.Errorf("flags = %q %d %v %v", *c.Name, *c.Port, *c.Debug, *c.Timeout)
+			}
+		})
+	}
+
+	port := 1
+	c := Config{Port: &port}
+	if err := ResetToDefaults(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != nil || c.Retries == nil || *c.Retries != 3 {
+		t.Errorf("ResetToDefaults = %+v, want nil Port and Retries 3", c)
+	}
+}
+
+func TestPrimitivePointerValidators(t *testing.T) {
+	type Config struct {
+		Email *string `flag:"email,pattern:^[^@]+@[^@]+$"`
+		Port  *int    `flag:"port,min:1,max:65535"`
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "omitted"},
+		{name: "valid", args: []string{"--email", "a@b", "--port", "8080"}},
+		{name: "pattern", args: []string{"--email", "ab"}, wantErr: true},
+		{name: "range", args: []string{"--port", "0"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Config
+			cmd, vp := newTestCommand()
+			if err := BindFlags(cmd, &c, WithViperOption(vp)); err != nil {
+				t.Fatal(err)
+			}
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := ValidateDefaults(&Config{}); err != nil {
+		t.Errorf("ValidateDefaults() = %v", err)
+	}
+}
+
+func TestDefaultErrorPath(t *testing.T) {
+	type Server struct {
+		Port int32 `flag:"port,default:abc"`
+	}
+	type Config struct {
+		B Server `flag:"b"`
+	}
+
+	want := `B.Port: cannot parse default value "abc" as int32`
+	cmd, vp := newTestCommand()
+	errs := map[string]error{
+		"BindFlags":        BindFlags(cmd, &Config{}, WithViperOption(vp)),
+		"ValidateDefaults": ValidateDefaults(&Config{}),
+		"ResetToDefaults":  ResetToDefaults(&Config{}),
+	}
+	for name, err := range errs {
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%s = %v, want prefix %q", name, err, want)
+		}
+	}
+}
+
+func TestAutoUnMarshalRunOnly(t *testing.T) {
+	type Config struct {
+		Port int `flag:"port,default:1"`
+	}
+
+	var (
+		c         Config
+		pre, post bool
+	)
+	cmd, vp := newTestCommand()
+	err := BindFlags(cmd, &c, WithViperOption(vp), WithAutoUnMarshalOption(),
+		WithPreAutoUnMarshalOption(func(*cobra.Command, []string) {
+			pre = true
+			vp.Set("port", 2)
+		}),
+		WithPostAutoUnMarshalOption(func(*cobra.Command, []string) { post = c.Port == 2 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !pre || !post {
+		t.Errorf("pre = %v, post = %v, want both hooks called around the unmarshal", pre, post)
+	}
+}
+
+func TestPostAutoUnMarshalHooks(t *testing.T) {
+	type Config struct {
+		Port int `flag:"port,default:1"`
+	}
+
+	tests := []struct {
+		name  string
+		setup func(cmd *cobra.Command)
+	}{
+		{name: "PreRun", setup: func(cmd *cobra.Command) { cmd.PreRun = func(*cobra.Command, []string) {} }},
+		{name: "PreRunE", setup: func(cmd *cobra.Command) { cmd.PreRunE = func(*cobra.Command, []string) error { return nil } }},
+		{name: "Run only", setup: func(*cobra.Command) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				c     Config
+				calls []string
+			)
+			cmd, vp := newTestCommand()
+			tt.setup(cmd)
+			err := BindFlags(cmd, &c, WithViperOption(vp), WithAutoUnMarshalOption(),
+				WithPostAutoUnMarshalOption(func(*cobra.Command, []string) {
+					calls = append(calls, fmt.Sprintf("post %d", c.Port))
+				}),
+				WithPostAutoUnMarshalEOption(func(*cobra.Command, []string) error {
+					calls = append(calls, fmt.Sprintf("postE %d", c.Port))
+					return nil
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			vp.Set("port", 2)
+			cmd.SetArgs(nil)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"post 2", "postE 2"}; !reflect.DeepEqual(calls, want) {
+				t.Errorf("calls = %v, want %v", calls, want)
+			}
+		})
+	}
+}
+
+func TestNormalizeRoundTrip(t *testing.T) {
+	type Config struct {
+		LogLevel string `flag:"log_level,default:info"`
+		MaxConns int    `flag:"max_conns"`
+	}
+	normalize := WithNormalizeOption(func(_ *flag.FlagSet, name string) flag.NormalizedName {
+		return flag.NormalizedName(strings.ReplaceAll(name, "_", "-"))
+	})
+
+	var c Config
+	cmd, vp := newTestCommand()
+	if err := BindFlags(cmd, &c, WithViperOption(vp), WithAutoUnMarshalOption(), normalize); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Flags().Lookup("log-level") == nil {
+		t.Fatal("flag log-level not registered")
+	}
+	cmd.SetArgs([]string{"--log_level", "debug", "--max-conns", "8"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Config{LogLevel: "debug", MaxConns: 8}
+	var r, u Config
+	if err := ReadFlags(&r, WithViperOption(vp), normalize); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalFlags(&u, WithViperOption(vp), normalize); err != nil {
+		t.Fatal(err)
+	}
+	if c != want || r != want || u != want {
+		t.Errorf("auto unmarshal = %+v, ReadFlags = %+v, UnmarshalFlags = %+v, want %+v", c, r, u, want)
+	}
+}
+
+func TestConfigFileNotFound(t *testing.T) {
+	type Config struct {
+		Host string `flag:"host,default:localhost"`
+	}
+
+	var (
+		c      Config
+		warned bool
+	)
+	logger := func(level, msg string, fields ...any) {
+		warned = warned || level == LogLevelWarn
+	}
+	cmd, vp := newTestCommand()
+	err := BindAndExecute(cmd, &c, WithViperOption(vp), WithAutoUnMarshalOption(), WithLoggerOption(logger),
+		WithConfigFileOption(t.TempDir()+"/none.yaml", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !warned {
+		t.Error("missing config file not logged as a warning")
+	}
+	if c.Host != "localhost" {
+		t.Errorf("Host = %q, want localhost", c.Host)
+	}
+}
+
+func TestDurationSliceDefault(t *testing.T) {
+	type Config struct {
+		Escaped []time.Duration `flag:"escaped,default:1s\\,5s\\,30s"`
+		Sep     []time.Duration `flag:"sep,default:1s|5s|30s,sep:|"`
+	}
+
+	var c Config
+	cmd, vp := newTestCommand()
+	if err := BindFlags(cmd, &c, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+	for name, got := range map[string][]time.Duration{"escaped": c.Escaped, "sep": c.Sep} {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestNestedSepExport(t *testing.T) {
+	type Server struct {
+		Port int `flag:"port,default:80"`
+	}
+	type Config struct {
+		Server Server `flag:"server"`
+	}
+	opts := []FlagOption{WithSquashOption(false), WithNestedSepOption("_")}
+
+	data, err := ExportFlags(nil, &Config{}, ExportJSON, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(strings.Fields(string(data)), ""), `{"server":{"port":80}}`; got != want {
+		t.Errorf("ExportFlags = %s, want %s", got, want)
+	}
+
+	schema, err := GenerateJSONSchema(&Config{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(schema), `"server": {`) || strings.Contains(string(schema), "server_port") {
+		t.Errorf("GenerateJSONSchema = %s, want the nested server", schema)
+	}
+
+	yaml, err := GenerateYAMLTemplate(&Config{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "server:\n  port: 80\n"; yaml != want {
+		t.Errorf("GenerateYAMLTemplate = %q, want %q", yaml, want)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	flag "github.com/spf13/pflag"
+)
+
+// ReadFlagsFromFlagSet read the fields from the current values of the flags without viper and cobra
+// e.g. set the values directly on the FlagSet in tests, the fields of the undefined flags are unchanged
+func ReadFlagsFromFlagSet(fs *flag.FlagSet, v0 builtin.Any, opts ...FlagOption) error {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return err
+	}
+
+	if err := checkStructPointer("ReadFlagsFromFlagSet", v0); err != nil {
+		return err
+	}
+
+	err = walkFlags(reflect.ValueOf(v0).Elem(), "", cfg, true, func(fValue reflect.Value, info *FlagInfo) error {
+		f := fs.Lookup(info.Name)
+		if f == nil {
+			return nil
+		}
+
+		value := reflect.New(info.typ).Elem()
+		if err := parseFlagValue(value, f.Value, info.tag); err != nil {
+			return fmt.Errorf("field %s: cannot parse flag %s value %q as %s: %w", info.FieldPath, info.Name, f.Value.String(), info.typ, err)
+		}
+		return setValue(fieldElem(fValue), info.field, info.tag, value.Interface(), cfg)
+	})
+	if err != nil {
+		return err
+	}
+	return validate(v0, cfg)
+}
+
+// parseFlagValue the elements of `pflag.SliceValue` are parsed one by one, e.g. `[a,b]` of stringSlice
+func parseFlagValue(v reflect.Value, value flag.Value, tag *tagData) error {
+	slice, ok := value.(flag.SliceValue)
+	if !ok || v.Kind() != reflect.Slice || isValueType(v.Type()) {
+		return parseValue(v, value.String(), tag)
+	}
+
+	items := slice.GetSlice()
+	l := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := parseValue(l.Index(i), item, tag); err != nil {
+			return err
+		}
+	}
+	v.Set(l)
+	return nil
+}
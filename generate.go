@@ -0,0 +1,502 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	"github.com/mars315/autoflags/lib/stringx"
+	flag "github.com/spf13/pflag"
+)
+
+/////////////////////////////////////////////////////// json schema ///////////////////////////////////////////////////////
+
+// jsonSchema the subset of JSON Schema draft-07 generated from the tags
+type jsonSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Default     builtin.Any            `json:"default,omitempty"`
+	Enum        []builtin.Any          `json:"enum,omitempty"`
+	Minimum     *float64               `json:"minimum,omitempty"`
+	Maximum     *float64               `json:"maximum,omitempty"`
+	Pattern     string                 `json:"pattern,omitempty"`
+	Items       *jsonSchema            `json:"items,omitempty"`
+	Properties  map[string]*jsonSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+}
+
+// GenerateJSONSchema generate the JSON Schema (draft-07) of the config file from the tags
+// the properties are nested by the viper keys, e.g. `server.port` -> {"server": {"properties": {"port": ...}}}
+func GenerateJSONSchema(v0 builtin.Any, opts ...FlagOption) ([]byte, error) {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ListFlags(v0, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &jsonSchema{Schema: "http://json-schema.org/draft-07/schema#", Type: "object"}
+	for i := range infos {
+		info := &infos[i]
+		keys := splitKey(info.tag.key(), cfg)
+		node := root
+		for j := 0; j < len(keys)-1; j++ {
+			child := node.property(keys[j])
+			// []struct, e.g. `servers.0.host`
+			if j+1 < len(keys)-1 && isIndexKey(keys[j+1]) {
+				child.Type = "array"
+				if child.Items == nil {
+					child.Items = &jsonSchema{Type: "object"}
+				}
+				node = child.Items
+				j++
+				continue
+			}
+			child.Type = "object"
+			node = child
+		}
+
+		name := keys[len(keys)-1]
+		node.setProperty(name, fieldSchema(info))
+		if info.Required {
+			node.require(name)
+		}
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func (s *jsonSchema) property(name string) *jsonSchema {
+	if child, ok := s.Properties[name]; ok {
+		return child
+	}
+	child := &jsonSchema{}
+	s.setProperty(name, child)
+	return child
+}
+
+func (s *jsonSchema) setProperty(name string, child *jsonSchema) {
+	if s.Properties == nil {
+		s.Properties = make(map[string]*jsonSchema)
+	}
+	s.Properties[name] = child
+}
+
+func (s *jsonSchema) require(name string) {
+	for _, v := range s.Required {
+		if v == name {
+			return
+		}
+	}
+	s.Required = append(s.Required, name)
+}
+
+func fieldSchema(info *FlagInfo) *jsonSchema {
+	s := schemaType(info.typ)
+	s.Description = info.Desc
+	if len(info.Default) > 0 {
+		s.Default = typedValue(info.typ, info.Default, info.tag.Sep)
+	}
+	for _, v := range info.tag.OneOf {
+		s.Enum = append(s.Enum, typedValue(info.typ, v, info.tag.Sep))
+	}
+	if isNumericType(info.typ) {
+		if v, err := strconv.ParseFloat(info.tag.Min, 64); err == nil {
+			s.Minimum = &v
+		}
+		if v, err := strconv.ParseFloat(info.tag.Max, 64); err == nil {
+			s.Maximum = &v
+		}
+	}
+	s.Pattern = info.tag.Pattern
+	return s
+}
+
+func schemaType(t reflect.Type) *jsonSchema {
+	if isStringType(t) {
+		return &jsonSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: schemaType(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object"}
+	default:
+		return &jsonSchema{Type: "string"}
+	}
+}
+
+/////////////////////////////////////////////////////// yaml ///////////////////////////////////////////////////////
+
+// yamlNode the key of the config file, in the order of the fields
+type yamlNode struct {
+	key      string
+	info     *FlagInfo
+	children []*yamlNode
+	// []struct, the children are the elements
+	list bool
+}
+
+// GenerateYAMLTemplate generate the config file template, the default is the value and the desc is the inline comment
+// e.g. `flag:"port,default:8080,desc:listen port"` -> port: 8080  # listen port
+func GenerateYAMLTemplate(v0 builtin.Any, opts ...FlagOption) (string, error) {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return "", err
+	}
+	infos, err := ListFlags(v0, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	root := &yamlNode{}
+	for i := range infos {
+		keys := splitKey(infos[i].tag.key(), cfg)
+		node := root
+		for j := 0; j < len(keys)-1; j++ {
+			node = node.child(keys[j])
+			if j+1 < len(keys)-1 && isIndexKey(keys[j+1]) {
+				node.list = true
+			}
+		}
+		node.child(keys[len(keys)-1]).info = &infos[i]
+	}
+
+	var sb strings.Builder
+	for _, node := range root.children {
+		node.write(&sb, "", "")
+	}
+	return sb.String(), nil
+}
+
+func (n *yamlNode) child(key string) *yamlNode {
+	for _, child := range n.children {
+		if child.key == key {
+			return child
+		}
+	}
+	child := &yamlNode{key: key}
+	n.children = append(n.children, child)
+	return child
+}
+
+// write the first line is prefixed with `first`, e.g. "- " of the list element, the others with `indent`
+func (n *yamlNode) write(sb *strings.Builder, first, indent string) {
+	sb.WriteString(first + n.key + ":")
+	switch {
+	case n.info != nil:
+		n.writeValue(sb, indent)
+	case n.list:
+		sb.WriteString("\n")
+		for _, elem := range n.children {
+			for i, child := range elem.children {
+				switch i {
+				case 0:
+					child.write(sb, indent+"  - ", indent+"    ")
+				default:
+					child.write(sb, indent+"    ", indent+"    ")
+				}
+			}
+		}
+	default:
+		sb.WriteString("\n")
+		for _, child := range n.children {
+			child.write(sb, indent+"  ", indent+"  ")
+		}
+	}
+}
+
+func (n *yamlNode) writeValue(sb *strings.Builder, indent string) {
+	comment := ""
+	if len(n.info.Desc) > 0 {
+		comment = "  # " + n.info.Desc
+	}
+
+	value := typedValue(n.info.typ, n.info.Default, n.info.tag.Sep)
+	list, ok := value.([]builtin.Any)
+	if !ok {
+		sb.WriteString(" " + yamlValue(n.info.typ, value) + comment + "\n")
+		return
+	}
+
+	if len(list) == 0 {
+		sb.WriteString(" []" + comment + "\n")
+		return
+	}
+	sb.WriteString(comment + "\n")
+	for _, v := range list {
+		sb.WriteString(indent + "  - " + yamlValue(n.info.typ.Elem(), v) + "\n")
+	}
+}
+
+// yamlValue the zero value is used for the empty default
+func yamlValue(t reflect.Type, value builtin.Any) string {
+	if isStringType(t) {
+		return strconv.Quote(fmt.Sprint(value))
+	}
+	if s, ok := value.(string); ok && len(s) == 0 {
+		return fmt.Sprint(reflect.Zero(t).Interface())
+	}
+	return fmt.Sprint(value)
+}
+
+/////////////////////////////////////////////////////// env ///////////////////////////////////////////////////////
+
+// GenerateEnvTemplate generate the .env file, the desc is the comment line above each variable
+// the names are the upper snake case of the viper keys with the env prefix, e.g. `server.http-port` -> APP_SERVER_HTTP_PORT
+// the explicit `env` label is used as is, the hidden fields are omitted
+func GenerateEnvTemplate(v0 builtin.Any, opts ...FlagOption) (string, error) {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return "", err
+	}
+	infos, err := ListFlags(v0, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i := range infos {
+		info := &infos[i]
+		if info.Hidden {
+			continue
+		}
+
+		value := info.Default
+		if info.Kind == reflect.Slice && !isStringType(info.typ) {
+			value = strings.Join(stringx.SafeTokens(value, info.tag.Sep), ",")
+		}
+
+		if len(info.Desc) > 0 {
+			sb.WriteString("# " + info.Desc + "\n")
+		}
+		sb.WriteString(envVarName(info, cfg) + "=" + envValue(value) + "\n")
+	}
+	return sb.String(), nil
+}
+
+// envVarName the explicit `env` label is used as is, e.g. `server.http-port` -> APP_SERVER_HTTP_PORT
+func envVarName(info *FlagInfo, cfg *FlagConfig) string {
+	if len(info.tag.Env) > 0 {
+		return info.tag.Env
+	}
+
+	name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(info.tag.key()))
+	if len(cfg.envPrefix) > 0 {
+		name = strings.ToUpper(cfg.envPrefix) + "_" + name
+	}
+	return name
+}
+
+// envValue quote the value with the special characters of the .env file
+func envValue(value string) string {
+	if strings.ContainsAny(value, " \t#\"'$\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+/////////////////////////////////////////////////////// markdown ///////////////////////////////////////////////////////
+
+// GenerateMarkdownDocs generate the markdown table of the flags
+// the nested structs in non-squash mode are the sections named after the struct, e.g. ## server
+// the hidden flags are omitted unless `WithIncludeHiddenOption` is set
+func GenerateMarkdownDocs(v0 builtin.Any, opts ...FlagOption) (string, error) {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return "", err
+	}
+	infos, err := ListFlags(v0, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	// the flags of the top struct come first, then the sections in the order of the fields
+	sections := map[string][]*FlagInfo{"": nil}
+	names := []string{""}
+	for i := range infos {
+		info := &infos[i]
+		if info.Hidden && !cfg.includeHidden {
+			continue
+		}
+
+		name := strings.Join(info.parent, cfg.nestedSep)
+		if _, ok := sections[name]; !ok {
+			names = append(names, name)
+		}
+		sections[name] = append(sections[name], info)
+	}
+
+	var sb strings.Builder
+	for _, name := range names {
+		if len(sections[name]) == 0 {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		if len(name) > 0 {
+			level := len(sections[name][0].parent) + 1
+			if level > 6 {
+				level = 6
+			}
+			sb.WriteString(strings.Repeat("#", level) + " " + name + "\n\n")
+		}
+
+		sb.WriteString("| Flag | Short | Type | Default | Description | Required |\n")
+		sb.WriteString("|------|-------|------|---------|-------------|----------|\n")
+		for _, info := range sections[name] {
+			short := ""
+			if len(info.Short) > 0 {
+				short = "`-" + info.Short + "`"
+			}
+			required := ""
+			if info.Required {
+				required = "yes"
+			}
+			sb.WriteString(fmt.Sprintf("| `--%s` | %s | %s | %s | %s | %s |\n",
+				info.Name, short, flagType(info), markdownCell(info.Default), markdownCell(info.Desc), required))
+		}
+	}
+	return sb.String(), nil
+}
+
+// flagType the pflag type name, e.g. stringSlice, duration
+func flagType(info *FlagInfo) string {
+	tag := *info.tag
+	tag.Default = ""
+	flagSet := flag.NewFlagSet("", flag.ContinueOnError)
+	if err := bindValue(flagSet, reflect.New(info.typ).Elem(), info.field, &tag); err != nil {
+		return info.typ.String()
+	}
+	return flagSet.Lookup(tag.Name).Value.Type()
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+/////////////////////////////////////////////////////// table ///////////////////////////////////////////////////////
+
+// PrintFlagTable write the tab-aligned table of the flags without binding, used to check the tags, e.g. in `init()`
+// the required flags are marked with `*`, the hidden flags are marked with `[hidden]`
+func PrintFlagTable(w io.Writer, v0 builtin.Any, opts ...FlagOption) error {
+	infos, err := ListFlags(v0, opts...)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Flag\tShort\tType\tDefault\tDescription")
+	for i := range infos {
+		info := &infos[i]
+		name := "--" + info.Name
+		if info.Required {
+			name += "*"
+		}
+		if info.Hidden {
+			name += " [hidden]"
+		}
+		short := ""
+		if len(info.Short) > 0 {
+			short = "-" + info.Short
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", name, short, flagType(info), info.Default, info.Desc)
+	}
+	return tw.Flush()
+}
+
+/////////////////////////////////////////////////////// helper ///////////////////////////////////////////////////////
+
+// isStringType the types written as string in the config file, e.g. time.Duration `1s`, net.IP, []byte base64, pflag.Value
+func isStringType(t reflect.Type) bool {
+	if isFlagValueType(t) || isTextType(t) {
+		return true
+	}
+
+	switch t {
+	case reflect.TypeOf(net.IP{}), reflect.TypeOf([]byte{}):
+		return true
+	}
+	// the other named int64 types are written as integer, see `typedValue`
+	if isDurationType(t) {
+		return true
+	}
+	return t.Kind() == reflect.String
+}
+
+// typedValue convert the tag value to the type of the field, the string is returned if the conversion fails
+func typedValue(t reflect.Type, s string, sep string) builtin.Any {
+	if isStringType(t) {
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if v, err := stringx.ParseBool(s); err == nil {
+			return v
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return v
+		}
+	case reflect.Float32, reflect.Float64:
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+	case reflect.Slice:
+		list := make([]builtin.Any, 0)
+		for _, v := range stringx.SafeTokens(s, sep) {
+			list = append(list, typedValue(t.Elem(), v, sep))
+		}
+		return list
+	case reflect.Map:
+		if m, err := parseBoolMap(s, sep); err == nil && t == reflect.TypeOf(map[string]bool{}) {
+			return m
+		}
+		if m, err := parseJSONMap(s); err == nil && t == jsonMapType {
+			return m
+		}
+	}
+	return s
+}
+
+// splitKey split the viper key into the nested keys by `WithNestedSepOption`, e.g. `server.port` -> [server port]
+func splitKey(key string, cfg *FlagConfig) []string {
+	if len(cfg.nestedSep) == 0 {
+		return []string{key}
+	}
+	return strings.Split(key, cfg.nestedSep)
+}
+
+// isIndexKey the index of []struct in the key
+func isIndexKey(key string) bool {
+	_, err := strconv.Atoi(key)
+	return err == nil
+}
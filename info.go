@@ -0,0 +1,229 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	"github.com/spf13/cobra"
+)
+
+// FlagInfo the metadata of a flag
+type FlagInfo struct {
+	Name, Short, Desc, Default, FieldPath string
+	Kind                                  reflect.Kind
+	Required, Hidden                      bool
+
+	// the field, the field type and the parsed tag, used by the generators
+	field reflect.StructField
+	typ   reflect.Type
+	tag   *tagData
+	// the nested struct names in non-squash mode
+	parent []string
+}
+
+// ListFlags return the metadata of all flags without binding to any command or FlagSet
+// the struct is traversed the same way as `BindFlags`, the nil struct pointers are not allocated
+func ListFlags(v0 builtin.Any, opts ...FlagOption) ([]FlagInfo, error) {
+	cfg, err := defaultFlagConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkStructPointer("ListFlags", v0); err != nil {
+		return nil, err
+	}
+
+	var infos []FlagInfo
+	err = walkFlags(reflect.ValueOf(v0).Elem(), "", cfg, false, func(_ reflect.Value, info *FlagInfo) error {
+		infos = append(infos, *info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// GetBoundFlagNames return the flag names that `BindFlags` would register with the same options, in the order of the fields
+func GetBoundFlagNames(v0 builtin.Any, opts ...FlagOption) ([]string, error) {
+	infos, err := ListFlags(v0, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	return names, nil
+}
+
+// DiffFlags return the names of the flags explicitly set on the command line
+func DiffFlags(cmd *cobra.Command, v0 builtin.Any, opts ...FlagOption) ([]string, error) {
+	infos, err := ListFlags(v0, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, info := range infos {
+		if cmd.Flags().Changed(info.Name) || cmd.PersistentFlags().Changed(info.Name) {
+			changed = append(changed, info.Name)
+		}
+	}
+	return changed, nil
+}
+
+// CheckConflicts return the flag names declared more than once by the structs, all conflicts are joined
+// e.g. CheckConflicts(&ServerConfig{}, &DBConfig{}) in `TestMain` or `init()`, instead of the pflag panic on binding
+func CheckConflicts(structs ...builtin.Any) error {
+	return checkConflicts(structs)
+}
+
+func checkConflicts(structs []builtin.Any, opts ...FlagOption) error {
+	owners := make(map[string]string)
+	var errs []error
+	for _, v0 := range structs {
+		infos, err := ListFlags(v0, opts...)
+		if err != nil {
+			return err
+		}
+		name := reflect.TypeOf(v0).Elem().Name()
+		for _, info := range infos {
+			path := name + "." + info.FieldPath
+			if owner, ok := owners[info.Name]; ok {
+				errs = append(errs, fmt.Errorf("flag %q defined in %s and %s", info.Name, owner, path))
+				continue
+			}
+			owners[info.Name] = path
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// walkFunc called with each non-struct field
+type walkFunc func(fValue reflect.Value, info *FlagInfo) error
+
+// walkFlags traverse the struct the same way as `bindFlags`,
+// the nil struct pointers are allocated if alloc is true, otherwise walked with the zero value, see `walkField`
+func walkFlags(v reflect.Value, path string, cfg *FlagConfig, alloc bool, fn walkFunc) error {
+	t := v.Type()
+	defer visitType(t, cfg)()
+	tags := getFieldTags(t, cfg)
+	for i := 0; i < v.NumField(); i++ {
+		fValue := v.Field(i)
+		field := t.Field(i)
+		tag := parseTag(field, tags[i], cfg)
+		if tag == nil {
+			continue
+		}
+
+		fieldPath := field.Name
+		if len(path) > 0 {
+			fieldPath = path + "." + field.Name
+		}
+
+		var err error
+		switch {
+		case isStructSlice(field.Type):
+			err = walkStructSlice(fValue, fieldPath, tag, cfg, alloc, fn)
+		case isStepInto(field):
+			err = walkStruct(fValue, field, fieldPath, tag, cfg, alloc, fn)
+		case isPrimitivePointer(field.Type):
+			err = walkField(fValue, field, fieldPath, tag, cfg, alloc, fn)
+		case fValue.Kind() == reflect.Pointer && !isValueType(field.Type):
+			err = fmt.Errorf("unsupported type: %s|%s(%s)", field.Name, fValue.Kind(), fValue.Type().Elem().Kind())
+		default:
+			err = walkField(fValue, field, fieldPath, tag, cfg, alloc, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkField the pointer to primitive is walked with the pointed-to value,
+// if alloc is true, the pointer itself is walked, so that it is allocated by `fieldElem` only when the value is set
+func walkField(fValue reflect.Value, field reflect.StructField, path string, tag *tagData, cfg *FlagConfig, alloc bool, fn walkFunc) error {
+	typ := field.Type
+	if isPrimitivePointer(typ) {
+		switch {
+		case alloc:
+		case fValue.IsNil():
+			fValue = reflect.New(typ.Elem()).Elem()
+		default:
+			fValue = fValue.Elem()
+		}
+		typ = typ.Elem()
+	}
+
+	return fn(fValue, &FlagInfo{
+		Name:      tag.Name,
+		Short:     tag.Short,
+		Desc:      tag.Desc,
+		Default:   tag.Default,
+		FieldPath: path,
+		Kind:      typ.Kind(),
+		Required:  tag.Required,
+		Hidden:    tag.Hidden,
+		field:     field,
+		typ:       typ,
+		tag:       tag,
+		parent:    append([]string(nil), cfg.parent...),
+	})
+}
+
+// fieldElem the value of `FlagInfo.typ` to set, the pointer to primitive of the alloc walk is allocated
+func fieldElem(fValue reflect.Value) reflect.Value {
+	if isPrimitivePointer(fValue.Type()) {
+		return allocElem(fValue)
+	}
+	return fValue
+}
+
+// walkStruct struct and struct pointer
+func walkStruct(fValue reflect.Value, field reflect.StructField, path string, tag *tagData, cfg *FlagConfig, alloc bool, fn walkFunc) error {
+	defer stepInto(field, tag, cfg)()
+	t := field.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if err := checkCycle(t, field, cfg); err != nil {
+		return err
+	}
+	if fValue.Kind() == reflect.Pointer {
+		switch {
+		case alloc:
+			if err := allocPointer(fValue, field, cfg); err != nil {
+				return err
+			}
+		case fValue.IsNil():
+			fValue = reflect.New(field.Type.Elem())
+		}
+		fValue = fValue.Elem()
+	}
+	return walkFlags(fValue, path, cfg, alloc, fn)
+}
+
+func walkStructSlice(fValue reflect.Value, path string, tag *tagData, cfg *FlagConfig, alloc bool, fn walkFunc) error {
+	parent := cfg.parent
+	defer func() { cfg.parent = parent }()
+
+	for i := 0; i < fValue.Len(); i++ {
+		cfg.parent = append(parent[:len(parent):len(parent)], tag.origin, strconv.Itoa(i))
+		if err := walkFlags(fValue.Index(i), fmt.Sprintf("%s[%d]", path, i), cfg, alloc, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"testing"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestEntryPointsRejectNonStructPointer(t *testing.T) {
+	type Config struct {
+		Port int `flag:"port"`
+	}
+
+	var n int
+	var nilConfig *Config
+	entries := map[string]func(v0 builtin.Any) error{
+		"BindFlags": func(v0 builtin.Any) error {
+			cmd, vp := newTestCommand()
+			return BindFlags(cmd, v0, WithViperOption(vp))
+		},
+		"ReadFlags": func(v0 builtin.Any) error {
+			return ReadFlags(v0, WithViperOption(viper.New()))
+		},
+		"UnmarshalFlags": func(v0 builtin.Any) error {
+			return UnmarshalFlags(v0, WithViperOption(viper.New()))
+		},
+		"ListFlags": func(v0 builtin.Any) error {
+			_, err := ListFlags(v0)
+			return err
+		},
+		"ValidateDefaults": func(v0 builtin.Any) error {
+			return ValidateDefaults(v0)
+		},
+		"ResetToDefaults": func(v0 builtin.Any) error {
+			return ResetToDefaults(v0)
+		},
+		"ReadFlagsFromEnv": func(v0 builtin.Any) error {
+			return ReadFlagsFromEnv(v0, "APP")
+		},
+		"ReadFlagsFromFlagSet": func(v0 builtin.Any) error {
+			return ReadFlagsFromFlagSet(flag.NewFlagSet("", flag.ContinueOnError), v0)
+		},
+	}
+	values := map[string]builtin.Any{
+		"nil":                nil,
+		"nil struct pointer": nilConfig,
+		"struct value":       Config{},
+		"int pointer":        &n,
+	}
+
+	for name, entry := range entries {
+		for desc, v0 := range values {
+			t.Run(name+"/"+desc, func(t *testing.T) {
+				if err := entry(v0); err == nil {
+					t.Errorf("%s(%s) must fail", name, desc)
+				}
+			})
+		}
+	}
+}
@@ -7,8 +7,12 @@
 package stringx
 
 import (
+	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/mars315/autoflags/lib/builtin"
 )
@@ -43,13 +47,22 @@ func SafeTokens(cmd string, sep string) []string {
 	return list
 }
 
+// ToBool "true", "t", "1", "yes", "y", "on" are true, case-insensitive, others are false
 func ToBool(s string) bool {
-	if len(s) == 0 {
-		return false
-	}
+	b, _ := ParseBool(s)
+	return b
+}
 
-	s = strings.ToLower(s)
-	return s == "true" || s == "t"
+// ParseBool like strconv.ParseBool, "yes", "y", "on" are true and "no", "n", "off" are false too, case-insensitive
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "1", "yes", "y", "on":
+		return true, nil
+	case "false", "f", "0", "no", "n", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid bool value %q", s)
+	}
 }
 
 // Atof string to float64
@@ -64,6 +77,36 @@ func Atoi[T builtin.SignedInteger](v string) T {
 	return T(vInt)
 }
 
+// AtofE string to float, returns the error of strconv.ParseFloat
+func AtofE[T builtin.Float](v string) (T, error) {
+	var zero T
+	vF64, err := strconv.ParseFloat(v, bitSize(zero))
+	return T(vF64), err
+}
+
+// AtoiE string to signed integer, returns the error of strconv.ParseInt
+func AtoiE[T builtin.SignedInteger](v string) (T, error) {
+	var zero T
+	vInt, err := strconv.ParseInt(v, 10, bitSize(zero))
+	return T(vInt), err
+}
+
+// bitSize the bit size of T for strconv, the named types are sized by their kinds
+func bitSize[T builtin.SignedInteger | builtin.Float](v T) int {
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Int8:
+		return 8
+	case reflect.Int16:
+		return 16
+	case reflect.Int32, reflect.Float32:
+		return 32
+	case reflect.Int64, reflect.Float64:
+		return 64
+	default:
+		return strconv.IntSize
+	}
+}
+
 // AtoSlice string to signed integer slice
 func AtoSlice[T builtin.SignedInteger](s string, sep string) []T {
 	ss := SafeTokens(s, sep)
@@ -77,3 +120,64 @@ func AtoSlice[T builtin.SignedInteger](s string, sep string) []T {
 	}
 	return l
 }
+
+// ToDurationSlice string to time.Duration slice, invalid durations are skipped
+func ToDurationSlice(s string, sep string) []time.Duration {
+	ss := SafeTokens(s, sep)
+	if len(ss) == 0 {
+		return nil
+	}
+
+	l := make([]time.Duration, 0, len(ss))
+	for _, v := range ss {
+		if d, err := time.ParseDuration(v); err == nil {
+			l = append(l, d)
+		}
+	}
+	return l
+}
+
+// SplitCamel split the camelCase or PascalCase string into words, "_" and "-" are separators too.
+// Acronyms are kept together, e.g. "HTTPSPort" -> ["HTTPS", "Port"], "DatabaseURL" -> ["Database", "URL"]
+func SplitCamel(s string) []string {
+	var (
+		words []string
+		word  []rune
+	)
+	runes := []rune(s)
+	for i, r := range runes {
+		if r == '_' || r == '-' || unicode.IsSpace(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = word[:0]
+			}
+			continue
+		}
+
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			// aB, 1B: lower (or digit) to upper
+			// ABc: the last upper of the acronym starts a new word
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				words = append(words, string(word))
+				word = word[:0]
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// ToKebab camelCase to kebab-case, e.g. "DatabaseURL" -> "database-url"
+func ToKebab(s string) string {
+	return strings.ToLower(strings.Join(SplitCamel(s), "-"))
+}
+
+// ToSnake camelCase to snake_case, e.g. "DatabaseURL" -> "database_url"
+func ToSnake(s string) string {
+	return strings.ToLower(strings.Join(SplitCamel(s), "_"))
+}
@@ -0,0 +1,117 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	"github.com/mars315/autoflags/lib/stringx"
+)
+
+// MergeFlags overwrite the fields of dst with the non-zero fields of srcs, the last non-zero wins
+// the fields are matched by the flag names, e.g. MergeFlags(&effective, &fileDefaults, &envOverrides, &cliFlags)
+// the nil pointers of dst are allocated only if a field under them is overwritten
+func MergeFlags(dst builtin.Any, srcs ...builtin.Any) error {
+	fields, err := mergeFields(dst)
+	if err != nil {
+		return err
+	}
+
+	for i, src := range srcs {
+		values, err := mergeFields(src)
+		if err != nil {
+			return fmt.Errorf("src %d: %w", i, err)
+		}
+
+		for name, value := range values {
+			field, ok := fields[name]
+			if !ok || isZeroValue(value.value) {
+				continue
+			}
+			if value.value.Type() != field.value.Type() {
+				return fmt.Errorf("src %d: flag `%s` type mismatch: %s, %s", i, name, value.value.Type(), field.value.Type())
+			}
+			fieldByPath(reflect.ValueOf(dst).Elem(), field.path).Set(cloneValue(value.value))
+		}
+	}
+	return nil
+}
+
+// mergeField the value of the nil pointer is the zero value not set to the struct
+type mergeField struct {
+	value reflect.Value
+	path  string
+}
+
+// mergeFields the fields of v0 by the flag names, the nil pointers are not allocated
+func mergeFields(v0 builtin.Any) (map[string]mergeField, error) {
+	t := reflect.TypeOf(v0)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct || reflect.ValueOf(v0).IsNil() {
+		return nil, fmt.Errorf("%T must be struct pointer", v0)
+	}
+
+	cfg, _ := defaultFlagConfig()
+	fields := make(map[string]mergeField)
+	err := walkFlags(reflect.ValueOf(v0).Elem(), "", cfg, false, func(fValue reflect.Value, info *FlagInfo) error {
+		fields[info.Name] = mergeField{value: fValue, path: info.FieldPath}
+		return nil
+	})
+	return fields, err
+}
+
+// fieldByPath the field of `FlagInfo.FieldPath`, e.g. Server.DB.Host or Servers[0].Host,
+// the nil pointers on the path are allocated, the pointer to primitive is dereferenced
+func fieldByPath(v reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		name, index, _ := strings.Cut(name, "[")
+		v = allocElem(v).FieldByName(name)
+		if len(index) > 0 {
+			v = v.Index(stringx.Atoi[int](strings.TrimSuffix(index, "]")))
+		}
+	}
+	if isPrimitivePointer(v.Type()) {
+		v = allocElem(v)
+	}
+	return v
+}
+
+// allocElem the value pointed to by v, v is allocated if it is a nil pointer
+func allocElem(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Pointer {
+		return v
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return v.Elem()
+}
+
+// isZeroValue the nil pointer, the pointer to zero value and the empty slice are zero
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer:
+		return v.IsNil() || v.Elem().IsZero()
+	case reflect.Slice:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// cloneValue the slice is copied, so that dst does not share the elements with src
+func cloneValue(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Slice {
+		return v
+	}
+
+	l := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(l, v)
+	return l
+}
@@ -0,0 +1,64 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"testing"
+)
+
+func TestMergeFlagsNilPointers(t *testing.T) {
+	type Database struct {
+		Host string `flag:"host"`
+	}
+	type Log struct {
+		Level string `flag:"level"`
+	}
+	type Config struct {
+		Name *string   `flag:"name"`
+		Port *int      `flag:"port"`
+		DB   *Database `flag:"db"`
+		Log  *Log      `flag:"log"`
+	}
+
+	port := 8080
+	var dst Config
+	if err := MergeFlags(&dst, &Config{Port: &port}, &Config{DB: &Database{Host: "db.example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pointers without source values stay nil
+	if dst.Name != nil || dst.Log != nil {
+		t.Errorf("Name = %v, Log = %v, want nil", dst.Name, dst.Log)
+	}
+	if dst.Port == nil || *dst.Port != 8080 {
+		t.Errorf("Port = %v, want 8080", dst.Port)
+	}
+	if dst.Port == &port {
+		t.Error("Port must not share the pointer of the source")
+	}
+	if dst.DB == nil || dst.DB.Host != "db.example.com" {
+		t.Errorf("DB = %+v, want db.example.com", dst.DB)
+	}
+}
+
+func TestMergeFlagsOrder(t *testing.T) {
+	type Config struct {
+		Host  string   `flag:"host"`
+		Port  int      `flag:"port"`
+		Tags  []string `flag:"tags"`
+		Debug bool     `flag:"debug"`
+	}
+
+	dst := Config{Host: "localhost", Port: 80}
+	err := MergeFlags(&dst, &Config{Port: 8080, Tags: []string{"a"}}, &Config{Port: 9090, Debug: true}, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.Host != "localhost" || dst.Port != 9090 || len(dst.Tags) != 1 || !dst.Debug {
+		t.Errorf("dst = %+v", dst)
+	}
+}
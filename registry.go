@@ -0,0 +1,42 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"github.com/mars315/autoflags/lib/builtin"
+	"github.com/spf13/cobra"
+)
+
+// FlagRegistry share the same options across multiple `BindFlags`, `ReadFlags` and `UnmarshalFlags` calls
+type FlagRegistry struct {
+	opts []FlagOption
+}
+
+// NewFlagRegistry e.g. NewFlagRegistry(WithTagNameOption("cli"), WithCamelToKebabOption())
+func NewFlagRegistry(baseOpts ...FlagOption) *FlagRegistry {
+	return &FlagRegistry{opts: append([]FlagOption(nil), baseOpts...)}
+}
+
+// Bind same as `BindFlags` with the base options and extraOpts
+func (r *FlagRegistry) Bind(cmd *cobra.Command, v0 builtin.Any, extraOpts ...FlagOption) error {
+	return BindFlags(cmd, v0, r.options(extraOpts...)...)
+}
+
+// Read same as `ReadFlags` with the base options
+func (r *FlagRegistry) Read(v0 builtin.Any) error {
+	return ReadFlags(v0, r.opts...)
+}
+
+// Unmarshal same as `UnmarshalFlags` with the base options
+func (r *FlagRegistry) Unmarshal(v0 builtin.Any) error {
+	return UnmarshalFlags(v0, r.opts...)
+}
+
+// options the base options are not modified by extraOpts
+func (r *FlagRegistry) options(extraOpts ...FlagOption) []FlagOption {
+	return append(r.opts[:len(r.opts):len(r.opts)], extraOpts...)
+}
@@ -0,0 +1,55 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mars315/autoflags/lib/builtin"
+)
+
+type (
+	// FlagTransformer transform the value read from viper before it is set to the field
+	// name is the flag name, kind is the `reflect.Kind` of the field
+	FlagTransformer interface {
+		Transform(name, kind string, value builtin.Any) (builtin.Any, error)
+	}
+
+	// FlagTransformerFunc adapter to allow the use of ordinary functions as FlagTransformer
+	FlagTransformerFunc func(name, kind string, value builtin.Any) (builtin.Any, error)
+)
+
+// Transform calls f(name, kind, value)
+func (f FlagTransformerFunc) Transform(name, kind string, value builtin.Any) (builtin.Any, error) {
+	return f(name, kind, value)
+}
+
+var (
+	// TrimTransformer remove the leading and trailing spaces of string values
+	TrimTransformer = stringTransformer(strings.TrimSpace)
+	// PathCleanTransformer normalize string values with `filepath.Clean`, empty strings are kept
+	PathCleanTransformer = stringTransformer(func(s string) string {
+		if len(s) == 0 {
+			return s
+		}
+		return filepath.Clean(s)
+	})
+	// ExpandEnvTransformer replace ${var} or $var in string values with the environment variables
+	ExpandEnvTransformer = stringTransformer(os.ExpandEnv)
+)
+
+// stringTransformer only transform string values, others are passed through
+func stringTransformer(fn func(string) string) FlagTransformerFunc {
+	return func(_, _ string, value builtin.Any) (builtin.Any, error) {
+		if s, ok := value.(string); ok {
+			return fn(s), nil
+		}
+		return value, nil
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestFlagTransformerChain(t *testing.T) {
+	type Config struct {
+		Path string `flag:"path"`
+		Port int    `flag:"port"`
+	}
+
+	tests := []struct {
+		name         string
+		transformers []FlagTransformer
+		want         string
+	}{
+		{name: "trim then clean", transformers: []FlagTransformer{TrimTransformer, PathCleanTransformer}, want: "a/c"},
+		// the spaces are part of the first and last path elements for filepath.Clean, so "./" and the trailing "/" are kept
+		{name: "clean then trim", transformers: []FlagTransformer{PathCleanTransformer, TrimTransformer}, want: "./a/c/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vp := viper.New()
+			vp.Set("path", "  ./a/b/../c/  ")
+			vp.Set("port", 8080)
+
+			var c Config
+			if err := ReadFlags(&c, WithViperOption(vp), WithFlagTransformerChainOption(tt.transformers...)); err != nil {
+				t.Fatal(err)
+			}
+			if c.Path != tt.want {
+				t.Errorf("Path = %q, want %q", c.Path, tt.want)
+			}
+			// the string transformers pass the other values through
+			if c.Port != 8080 {
+				t.Errorf("Port = %d, want 8080", c.Port)
+			}
+		})
+	}
+}
@@ -0,0 +1,722 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mars315/autoflags/lib/builtin"
+	"github.com/mars315/autoflags/lib/stringx"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+/////////////////////////////////////////////////////// text ///////////////////////////////////////////////////////
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// textValue string flag backed by `encoding.TextUnmarshaler`
+type textValue struct {
+	text encoding.TextUnmarshaler
+}
+
+var _ flag.Value = (*textValue)(nil)
+
+func (v *textValue) Set(s string) error {
+	return v.text.UnmarshalText([]byte(s))
+}
+
+func (v *textValue) String() string {
+	if m, ok := v.text.(encoding.TextMarshaler); ok {
+		b, _ := m.MarshalText()
+		return string(b)
+	}
+	return fmt.Sprint(v.text)
+}
+
+func (v *textValue) Type() string {
+	return "string"
+}
+
+// isTextType the type (or pointer-to-type) implements `encoding.TextUnmarshaler`
+func isTextType(t reflect.Type) bool {
+	return t.Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// textUnmarshaler nil pointers are allocated
+func textUnmarshaler(fValue reflect.Value) (encoding.TextUnmarshaler, bool) {
+	if fValue.Kind() == reflect.Pointer && fValue.Type().Implements(textUnmarshalerType) {
+		if fValue.IsNil() {
+			fValue.Set(reflect.New(fValue.Type().Elem()))
+		}
+		return fValue.Interface().(encoding.TextUnmarshaler), true
+	}
+
+	if reflect.PointerTo(fValue.Type()).Implements(textUnmarshalerType) {
+		return fValue.Addr().Interface().(encoding.TextUnmarshaler), true
+	}
+	return nil, false
+}
+
+// bindText the current value of the field is the default unless `default` is set
+func bindText(flagSet *flag.FlagSet, text encoding.TextUnmarshaler, field reflect.StructField, tag *tagData) error {
+	if len(tag.Default) > 0 {
+		if err := text.UnmarshalText([]byte(tag.Default)); err != nil {
+			return fmt.Errorf("field `%s` invalid default %q: %w", field.Name, tag.Default, err)
+		}
+	}
+	flagSet.VarP(&textValue{text: text}, tag.Name, tag.Short, tag.Desc)
+	return nil
+}
+
+func readText(text encoding.TextUnmarshaler, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	value, err := transform(reflect.String, field, tag, getViper(cfg).GetString(tag.key()), cfg)
+	if err != nil {
+		return err
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("field `%s` cannot be set with %T", field.Name, value)
+	}
+	return text.UnmarshalText([]byte(s))
+}
+
+/////////////////////////////////////////////////////// value ///////////////////////////////////////////////////////
+
+var (
+	flagValueType = reflect.TypeOf((*flag.Value)(nil)).Elem()
+)
+
+// isFlagValueType the pointer-to-type (or the pointer type itself) implements `pflag.Value`
+func isFlagValueType(t reflect.Type) bool {
+	return (t.Kind() == reflect.Pointer && t.Implements(flagValueType)) || reflect.PointerTo(t).Implements(flagValueType)
+}
+
+// flagValue nil pointers are allocated
+func flagValue(fValue reflect.Value) (flag.Value, bool) {
+	if fValue.Kind() == reflect.Pointer && fValue.Type().Implements(flagValueType) {
+		if fValue.IsNil() {
+			fValue.Set(reflect.New(fValue.Type().Elem()))
+		}
+		return fValue.Interface().(flag.Value), true
+	}
+
+	if reflect.PointerTo(fValue.Type()).Implements(flagValueType) {
+		return fValue.Addr().Interface().(flag.Value), true
+	}
+	return nil, false
+}
+
+// bindFlagValue the field is registered as is, the current value of the field is the default unless `default` is set
+func bindFlagValue(flagSet *flag.FlagSet, value flag.Value, field reflect.StructField, tag *tagData) error {
+	if len(tag.Default) > 0 {
+		if err := value.Set(tag.Default); err != nil {
+			return fmt.Errorf("field `%s` invalid default %q: %w", field.Name, tag.Default, err)
+		}
+	}
+	flagSet.VarP(value, tag.Name, tag.Short, tag.Desc)
+	return nil
+}
+
+// readFlagValue viper returns `Value.String()` of the custom types,
+// the value is set only if it differs, so that the bound field is not set twice
+func readFlagValue(value flag.Value, field reflect.StructField, tag *tagData, cfg *FlagConfig) error {
+	v, err := transform(reflect.String, field, tag, getViper(cfg).GetString(tag.key()), cfg)
+	if err != nil {
+		return err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("field `%s` cannot be set with %T", field.Name, v)
+	}
+	if s == value.String() {
+		return nil
+	}
+	return value.Set(s)
+}
+
+/////////////////////////////////////////////////////// time ///////////////////////////////////////////////////////
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// timeValue time.Time parsed with the `layout` label
+type timeValue struct {
+	value  *time.Time
+	layout string
+}
+
+var _ flag.Value = (*timeValue)(nil)
+
+func (v *timeValue) Set(s string) error {
+	t, err := time.Parse(v.layout, strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	*v.value = t
+	return nil
+}
+
+// String the zero time is empty, so that it is not shown as the default
+func (v *timeValue) String() string {
+	if v.value.IsZero() {
+		return ""
+	}
+	return v.value.Format(v.layout)
+}
+
+func (v *timeValue) Type() string {
+	return "time"
+}
+
+// timeLayout default is time.RFC3339
+func timeLayout(tag *tagData) string {
+	if len(tag.Layout) > 0 {
+		return tag.Layout
+	}
+	return time.RFC3339
+}
+
+// bindTime the layout is appended to the description, e.g. `creation date (layout: 2006-01-02)`
+func bindTime(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) error {
+	value := &timeValue{value: fValue.Addr().Interface().(*time.Time), layout: timeLayout(tag)}
+	// the default is checked by `parseDefault`
+	if len(tag.Default) > 0 {
+		_ = value.Set(tag.Default)
+	}
+
+	desc := "layout: " + value.layout
+	if len(tag.Desc) > 0 {
+		desc = tag.Desc + " (" + desc + ")"
+	}
+	flagSet.VarP(value, tag.Name, tag.Short, desc)
+	return nil
+}
+
+// readTime the config file may have been decoded as time.Time, e.g. the yaml timestamp
+func readTime(vp *viper.Viper, tag *tagData) (time.Time, error) {
+	switch value := vp.Get(tag.key()).(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return value, nil
+	default:
+		s := strings.TrimSpace(cast.ToString(value))
+		if len(s) == 0 {
+			return time.Time{}, nil
+		}
+		return time.Parse(timeLayout(tag), s)
+	}
+}
+
+/////////////////////////////////////////////////////// duration ///////////////////////////////////////////////////////
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	// reflect.Type -> struct{}, the named duration types of `RegisterDurationType`
+	durationTypes sync.Map
+)
+
+// RegisterDurationType register the named duration type T, e.g. `type Timeout time.Duration`,
+// reflect cannot tell it apart from `type UserID int64`, so the unregistered named int64 types are bound as int64
+func RegisterDurationType[T ~int64]() {
+	durationTypes.Store(reflect.TypeOf((*T)(nil)).Elem(), struct{}{})
+}
+
+// isDurationType time.Duration and the types of `RegisterDurationType`
+func isDurationType(t reflect.Type) bool {
+	if t == durationType {
+		return true
+	}
+	_, ok := durationTypes.Load(t)
+	return ok
+}
+
+// bindDuration the registered duration types are bound through the pointer to time.Duration
+func bindDuration(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) {
+	duration, _ := time.ParseDuration(tag.Default)
+	p := fValue.Addr().Convert(reflect.TypeOf((*time.Duration)(nil))).Interface().(*time.Duration)
+	flagSet.DurationVarP(p, tag.Name, tag.Short, duration, tag.Desc)
+}
+
+// stringToDurationHookFunc the registered duration types of `UnmarshalFlags`, e.g. `5s` -> Timeout,
+// mapstructure only converts the string to time.Duration itself
+func stringToDurationHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data builtin.Any) (builtin.Any, error) {
+		if f.Kind() != reflect.String || !isDurationType(t) || t == durationType {
+			return data, nil
+		}
+		d, err := time.ParseDuration(data.(string))
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(d).Convert(t).Interface(), nil
+	}
+}
+
+/////////////////////////////////////////////////////// hex ///////////////////////////////////////////////////////
+
+// hexIntValue the integer accepts the `0x` prefix, e.g. `format:hex` -> --addr 0x1A2B
+type hexIntValue struct {
+	value reflect.Value
+}
+
+var _ flag.Value = (*hexIntValue)(nil)
+
+func (v *hexIntValue) Set(s string) error {
+	return parseHexInt(v.value, s)
+}
+
+// String e.g. 0xFF00
+func (v *hexIntValue) String() string {
+	switch {
+	case v.value.CanUint():
+		return fmt.Sprintf("0x%X", v.value.Uint())
+	case v.value.Int() < 0:
+		return fmt.Sprintf("-0x%X", -v.value.Int())
+	default:
+		return fmt.Sprintf("0x%X", v.value.Int())
+	}
+}
+
+func (v *hexIntValue) Type() string {
+	return "hex"
+}
+
+// isHexInt the integers with `format:hex`, except the duration types
+func isHexInt(t reflect.Type, tag *tagData) bool {
+	if tag.Format != FormatHex || isDurationType(t) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseHexInt the base is implied by the prefix, e.g. 0x, 0o, 0b, otherwise decimal
+func parseHexInt(v reflect.Value, s string) error {
+	s = strings.TrimSpace(s)
+	if v.CanUint() {
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+		return nil
+	}
+
+	n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+	if err != nil {
+		return err
+	}
+	v.SetInt(n)
+	return nil
+}
+
+// bindHexInt the default is checked by `parseDefault`
+func bindHexInt(flagSet *flag.FlagSet, fValue reflect.Value, tag *tagData) {
+	value := &hexIntValue{value: fValue}
+	if len(tag.Default) > 0 {
+		_ = value.Set(tag.Default)
+	} else {
+		fValue.Set(reflect.Zero(fValue.Type()))
+	}
+	f := flagSet.VarPF(value, tag.Name, tag.Short, tag.Desc)
+	// pflag shows the default unless it is empty or `0`, e.g. `(default 0x0)`
+	if len(tag.Default) == 0 {
+		f.DefValue = ""
+	}
+}
+
+// readHexInt the value of the field type, the config file may have the decimal integer
+func readHexInt(vp *viper.Viper, fValue reflect.Value, tag *tagData) (builtin.Any, error) {
+	value := reflect.New(fValue.Type()).Elem()
+	if s := vp.GetString(tag.key()); len(s) > 0 {
+		if err := parseHexInt(value, s); err != nil {
+			return nil, err
+		}
+	}
+	return value.Interface(), nil
+}
+
+/////////////////////////////////////////////////////// bool map ///////////////////////////////////////////////////////
+
+// boolMapValue `key=true,key2=false`, the key without value is true, e.g. `cache,debug=false`
+// the first `Set` replaces the default, the later ones are merged, same as `StringToString` of pflag
+type boolMapValue struct {
+	value   *map[string]bool
+	sep     string
+	changed bool
+}
+
+var _ flag.Value = (*boolMapValue)(nil)
+
+func newBoolMapValue(p *map[string]bool, sep string) *boolMapValue {
+	return &boolMapValue{value: p, sep: sep}
+}
+
+func (v *boolMapValue) Set(s string) error {
+	m, err := parseBoolMap(s, v.sep)
+	if err != nil {
+		return err
+	}
+
+	if !v.changed || *v.value == nil {
+		*v.value = m
+	} else {
+		for key, b := range m {
+			(*v.value)[key] = b
+		}
+	}
+	v.changed = true
+	return nil
+}
+
+// String sorted by key, e.g. `auth=true,cache=false`
+func (v *boolMapValue) String() string {
+	keys := make([]string, 0, len(*v.value))
+	for key := range *v.value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+strconv.FormatBool((*v.value)[key]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v *boolMapValue) Type() string {
+	return "stringToBool"
+}
+
+// parseBoolMap the empty string is the nil map
+func parseBoolMap(s string, sep string) (map[string]bool, error) {
+	tokens := stringx.SafeTokens(s, sep)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	m := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		key, value, ok := strings.Cut(token, "=")
+		key = strings.TrimSpace(key)
+		if len(key) == 0 {
+			return nil, fmt.Errorf("empty key in %q", token)
+		}
+		if !ok {
+			m[key] = true
+			continue
+		}
+
+		b, err := stringx.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of key %q: %w", key, err)
+		}
+		m[key] = b
+	}
+	return m, nil
+}
+
+/////////////////////////////////////////////////////// json map ///////////////////////////////////////////////////////
+
+// jsonMapValue map[string]interface{}, e.g. `{"key":"value","num":42}`
+// every Set replaces the whole map
+type jsonMapValue struct {
+	value *map[string]builtin.Any
+}
+
+var _ flag.Value = (*jsonMapValue)(nil)
+
+func newJSONMapValue(p *map[string]builtin.Any) *jsonMapValue {
+	return &jsonMapValue{value: p}
+}
+
+func (v *jsonMapValue) Set(s string) error {
+	m, err := parseJSONMap(s)
+	if err != nil {
+		return err
+	}
+	*v.value = m
+	return nil
+}
+
+func (v *jsonMapValue) String() string {
+	if *v.value == nil {
+		return ""
+	}
+	b, err := json.Marshal(*v.value)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (v *jsonMapValue) Type() string {
+	return "json"
+}
+
+// jsonMapType map[string]interface{}
+var jsonMapType = reflect.TypeOf(map[string]builtin.Any{})
+
+// parseJSONMap the empty string is the nil map
+func parseJSONMap(s string) (map[string]builtin.Any, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return nil, nil
+	}
+
+	var m map[string]builtin.Any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, fmt.Errorf("invalid JSON object %q: %w", s, err)
+	}
+	return m, nil
+}
+
+/////////////////////////////////////////////////////// validate ///////////////////////////////////////////////////////
+
+// validateValue validate the value after it is set, the elements of the slice value are validated one by one
+type validateValue struct {
+	flag.Value
+	validate func(s string) error
+}
+
+func (v *validateValue) Set(s string) error {
+	if err := v.Value.Set(s); err != nil {
+		return err
+	}
+	if slice, ok := v.Value.(flag.SliceValue); ok {
+		return validateEach(v.validate, slice.GetSlice())
+	}
+	return v.validate(v.Value.String())
+}
+
+// validateSliceValue keep the `pflag.SliceValue` of the wrapped value, e.g. `--formats json --formats yaml`
+type validateSliceValue struct {
+	*validateValue
+	slice flag.SliceValue
+}
+
+var _ flag.SliceValue = (*validateSliceValue)(nil)
+
+func (v *validateSliceValue) Append(s string) error {
+	if err := v.validate(s); err != nil {
+		return err
+	}
+	return v.slice.Append(s)
+}
+
+func (v *validateSliceValue) Replace(ss []string) error {
+	if err := validateEach(v.validate, ss); err != nil {
+		return err
+	}
+	return v.slice.Replace(ss)
+}
+
+func (v *validateSliceValue) GetSlice() []string {
+	return v.slice.GetSlice()
+}
+
+func validateEach(validate func(s string) error, ss []string) error {
+	for _, s := range ss {
+		if err := validate(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addValidator validate the flag value at parse time, the usage is appended with the constraint
+func addValidator(f *flag.Flag, usage string, validate func(s string) error) {
+	value := &validateValue{Value: f.Value, validate: validate}
+	if slice, ok := f.Value.(flag.SliceValue); ok {
+		f.Value = &validateSliceValue{validateValue: value, slice: slice}
+	} else {
+		f.Value = value
+	}
+	if len(f.Usage) > 0 {
+		f.Usage += " "
+	}
+	f.Usage += "(" + usage + ")"
+}
+
+// markOneOf the default value must be one of the allowed values
+func markOneOf(f *flag.Flag, field reflect.StructField, tag *tagData) error {
+	return markValidator(f, oneOfValidator, field, tag)
+}
+
+// markRange int, int32, int64, float32, float64 only, the bounds are inclusive
+func markRange(f *flag.Flag, field reflect.StructField, tag *tagData) error {
+	return markValidator(f, rangeValidator, field, tag)
+}
+
+// markPattern the pattern is compiled at bind time
+func markPattern(f *flag.Flag, field reflect.StructField, tag *tagData) error {
+	return markValidator(f, patternValidator, field, tag)
+}
+
+// validatorFunc return the validate function and its usage, the default value is checked
+type validatorFunc func(field reflect.StructField, tag *tagData) (func(s string) error, string, error)
+
+func markValidator(f *flag.Flag, fn validatorFunc, field reflect.StructField, tag *tagData) error {
+	validate, usage, err := fn(field, tag)
+	if err != nil {
+		return err
+	}
+	addValidator(f, usage, validate)
+	return nil
+}
+
+func oneOfValidator(field reflect.StructField, tag *tagData) (func(s string) error, string, error) {
+	validate := func(s string) error {
+		for _, v := range tag.OneOf {
+			if s == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", s, strings.Join(tag.OneOf, "|"))
+	}
+
+	if err := validateDefault(validate, field, tag); err != nil {
+		return nil, "", err
+	}
+	return validate, "one of " + strings.Join(tag.OneOf, "|"), nil
+}
+
+func rangeValidator(field reflect.StructField, tag *tagData) (func(s string) error, string, error) {
+	if !isNumericType(validatedType(field)) {
+		return nil, "", fmt.Errorf("field `%s` label `%s`/`%s` requires numeric type, got %s", field.Name, TagLabelMin, TagLabelMax, field.Type)
+	}
+
+	bound := func(label, s string) (float64, bool, error) {
+		if len(s) == 0 {
+			return 0, false, nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("field `%s` invalid %s %q: %w", field.Name, label, s, err)
+		}
+		return v, true, nil
+	}
+	minValue, hasMin, err := bound(TagLabelMin, tag.Min)
+	if err != nil {
+		return nil, "", err
+	}
+	maxValue, hasMax, err := bound(TagLabelMax, tag.Max)
+	if err != nil {
+		return nil, "", err
+	}
+
+	validate := func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		if hasMin && v < minValue {
+			return fmt.Errorf("%s is less than %s", s, tag.Min)
+		}
+		if hasMax && v > maxValue {
+			return fmt.Errorf("%s is greater than %s", s, tag.Max)
+		}
+		return nil
+	}
+
+	if err := validateDefault(validate, field, tag); err != nil {
+		return nil, "", err
+	}
+
+	var usage []string
+	if hasMin {
+		usage = append(usage, TagLabelMin+" "+tag.Min)
+	}
+	if hasMax {
+		usage = append(usage, TagLabelMax+" "+tag.Max)
+	}
+	return validate, strings.Join(usage, ", "), nil
+}
+
+func patternValidator(field reflect.StructField, tag *tagData) (func(s string) error, string, error) {
+	if validatedType(field).Kind() != reflect.String {
+		return nil, "", fmt.Errorf("field `%s` label `%s` requires string type, got %s", field.Name, TagLabelPattern, field.Type)
+	}
+
+	re, err := regexp.Compile(tag.Pattern)
+	if err != nil {
+		return nil, "", fmt.Errorf("field %s: invalid pattern %q: %v", field.Name, tag.Pattern, err)
+	}
+
+	validate := func(s string) error {
+		if !re.MatchString(s) {
+			return fmt.Errorf("%q does not match %s", s, tag.Pattern)
+		}
+		return nil
+	}
+
+	if err := validateDefault(validate, field, tag); err != nil {
+		return nil, "", err
+	}
+	return validate, TagLabelPattern + " " + tag.Pattern, nil
+}
+
+// validateDefault the elements of the slice default are validated one by one, e.g. `default:json|yaml,sep:|`
+func validateDefault(validate func(s string) error, field reflect.StructField, tag *tagData) error {
+	if len(tag.Default) == 0 {
+		return nil
+	}
+
+	values := []string{tag.Default}
+	if field.Type.Kind() == reflect.Slice && !isValueType(field.Type) {
+		values = stringx.SafeTokens(tag.Default, tag.Sep)
+	}
+	if err := validateEach(validate, values); err != nil {
+		return fmt.Errorf("field `%s` invalid default: %w", field.Name, err)
+	}
+	return nil
+}
+
+// validatedType the type of the field value to validate, the pointer to primitive is dereferenced
+func validatedType(field reflect.StructField) reflect.Type {
+	if isPrimitivePointer(field.Type) {
+		return field.Type.Elem()
+	}
+	return field.Type
+}
+
+// isNumericType int, int32, int64, float32, float64, except time.Duration
+func isNumericType(t reflect.Type) bool {
+	if isDurationType(t) || isValueType(t) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
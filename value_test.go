@@ -0,0 +1,101 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+)
+
+func TestOneOfSlice(t *testing.T) {
+	type Config struct {
+		Formats []string `flag:"formats,oneof:json|yaml|toml,default:json\\,yaml"`
+	}
+
+	var c Config
+	cmd, vp := newTestCommand()
+	if err := BindFlags(cmd, &c, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"json", "yaml"}; !reflect.DeepEqual(c.Formats, want) {
+		t.Errorf("default Formats = %v, want %v", c.Formats, want)
+	}
+
+	slice, ok := cmd.Flags().Lookup("formats").Value.(flag.SliceValue)
+	if !ok {
+		t.Fatal("the oneof flag of the slice field must be a pflag.SliceValue")
+	}
+	if err := slice.Replace([]string{"toml", "xml"}); err == nil {
+		t.Error("Replace with xml must fail")
+	}
+	if err := slice.Replace([]string{"toml"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := slice.Append("xml"); err == nil {
+		t.Error("Append xml must fail")
+	}
+	if err := slice.Append("json"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"toml", "json"}; !reflect.DeepEqual(c.Formats, want) {
+		t.Errorf("Formats = %v, want %v", c.Formats, want)
+	}
+
+	// each element of the command line values is validated
+	if err := cmd.Flags().Set("formats", "json,xml"); err == nil {
+		t.Error("--formats json,xml must fail")
+	}
+}
+
+func TestOneOfSliceInvalidDefault(t *testing.T) {
+	type Config struct {
+		Formats []string `flag:"formats,oneof:json|yaml,default:json\\,xml"`
+	}
+
+	cmd, vp := newTestCommand()
+	if err := BindFlags(cmd, &Config{}, WithViperOption(vp)); err == nil {
+		t.Error("the default xml must fail")
+	}
+	if err := ValidateDefaults(&Config{}); err == nil {
+		t.Error("ValidateDefaults must report the default xml")
+	}
+}
+
+func TestHexIntDefaultUsage(t *testing.T) {
+	type Config struct {
+		Addr uint64 `flag:"addr,format:hex,default:0xFF00"`
+		Mask int    `flag:"mask,format:hex"`
+	}
+
+	var c Config
+	cmd, vp := newTestCommand()
+	if err := BindFlags(cmd, &c, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	usage := cmd.Flags().FlagUsages()
+	if !strings.Contains(usage, "(default 0xFF00)") {
+		t.Errorf("usage %q must show the default of addr", usage)
+	}
+	if strings.Contains(usage, "0x0") {
+		t.Errorf("usage %q must not show the zero default of mask", usage)
+	}
+
+	cmd.SetArgs([]string{"--mask", "0x0F"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var r Config
+	if err := ReadFlags(&r, WithViperOption(vp)); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{Addr: 0xFF00, Mask: 0x0F}); c != want || r != want {
+		t.Errorf("flags = %+v, ReadFlags = %+v, want %+v", c, r, want)
+	}
+}
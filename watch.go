@@ -0,0 +1,96 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mars315/autoflags/lib/builtin"
+)
+
+// watchConfig re-read the structs on every change of the config file, see `WithWatchConfigOption`
+// the file of `WithConfigFileOption` is watched even if it is read later by the auto unmarshal
+func watchConfig(cfg *FlagConfig, structs ...builtin.Any) {
+	if !cfg.watchConfig || cfg.noViper {
+		return
+	}
+
+	vp := getViper(cfg)
+	if len(cfg.configFile) > 0 {
+		vp.SetConfigFile(cfg.configFile)
+		if len(cfg.configType) > 0 {
+			vp.SetConfigType(cfg.configType)
+		}
+	}
+	vp.OnConfigChange(reloadFunc(cfg, structs...))
+	vp.WatchConfig()
+}
+
+// reloadFunc the handler of `viper.OnConfigChange`, viper has read the changed file before calling it
+func reloadFunc(cfg *FlagConfig, structs ...builtin.Any) func(in fsnotify.Event) {
+	limiter := newRateLimiter(cfg.rateLimit, cfg.rateWindow)
+	return func(in fsnotify.Event) {
+		if !limiter.allow() {
+			logMessage(cfg, LogLevelDebug, "config change dropped by the rate limit", "file", in.Name)
+			return
+		}
+
+		var errs []error
+		for _, v0 := range structs {
+			if err := unmarshalFlags(v0, cfg); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		err := errors.Join(errs...)
+		if err != nil {
+			logMessage(cfg, LogLevelError, "reload config failed", "file", in.Name, "error", err)
+		}
+		if cfg.onConfigChange != nil {
+			cfg.onConfigChange(err)
+		}
+	}
+}
+
+// rateLimiter the token bucket of n tokens, the bucket is refilled every window since the first token is taken
+type rateLimiter struct {
+	mu     sync.Mutex
+	n      int
+	window time.Duration
+	tokens int
+	refill time.Time
+	now    func() time.Time
+}
+
+// newRateLimiter nil if there is no limit, the nil limiter allows all
+func newRateLimiter(n int, window time.Duration) *rateLimiter {
+	if n <= 0 || window <= 0 {
+		return nil
+	}
+	return &rateLimiter{n: n, window: window, now: time.Now}
+}
+
+// allow take a token, false if the bucket is empty
+func (l *rateLimiter) allow() bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now := l.now(); !now.Before(l.refill) {
+		l.tokens = l.n
+		l.refill = now.Add(l.window)
+	}
+	if l.tokens == 0 {
+		return false
+	}
+	l.tokens--
+	return true
+}
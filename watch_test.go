@@ -0,0 +1,109 @@
+// Copyright © 2023 mars315 <254262243@qq.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+//
+
+package autoflags
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestWatchConfig(t *testing.T) {
+	type Config struct {
+		Port int `flag:"port,default:1"`
+	}
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("port: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	defer viper.Reset()
+	viper.SetConfigFile(file)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan error, 16)
+	var c Config
+	cmd := &cobra.Command{Use: "app"}
+	if err := BindFlags(cmd, &c, WithWatchConfigOption(func(err error) { reloaded <- err })); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(file, []byte("port: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change not reloaded")
+	}
+	if c.Port != 3 {
+		t.Errorf("Port = %d, want 3", c.Port)
+	}
+}
+
+func TestFlagRateLimit(t *testing.T) {
+	type Config struct {
+		Port int `flag:"port"`
+	}
+
+	var c Config
+	calls := 0
+	cfg, err := defaultFlagConfig(WithFlagRateLimitOption(3, time.Hour), WithWatchConfigOption(func(error) { calls++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reload := reloadFunc(cfg, &c)
+	for i := 0; i < 10; i++ {
+		reload(fsnotify.Event{Name: "config.yaml", Op: fsnotify.Write})
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(2, time.Second)
+	limiter.now = func() time.Time { return now }
+
+	allowed := func() int {
+		n := 0
+		for i := 0; i < 10; i++ {
+			if limiter.allow() {
+				n++
+			}
+		}
+		return n
+	}
+	if n := allowed(); n != 2 {
+		t.Errorf("allowed = %d, want 2", n)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if n := allowed(); n != 0 {
+		t.Errorf("allowed within the window = %d, want 0", n)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if n := allowed(); n != 2 {
+		t.Errorf("allowed after the window = %d, want 2", n)
+	}
+
+	if !newRateLimiter(0, time.Second).allow() {
+		t.Error("the limiter without limit must allow")
+	}
+}